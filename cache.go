@@ -2,6 +2,12 @@ package lfu
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,31 +15,213 @@ import (
 
 var ErrNotFound = errors.New("key not found")
 
+// ErrCacheStopped is returned by GetE and SetWithError after Stop when the
+// cache was created with WithStrictStop.
+var ErrCacheStopped = errors.New("cache stopped")
+
+// ErrInvalidConfig is wrapped by the errors NewWithError returns.
+var ErrInvalidConfig = errors.New("invalid cache configuration")
+
+// activeCaches counts caches that were created but not stopped.
+var activeCaches atomic.Int64
+
+// ActiveCaches returns how many caches were created and not yet stopped,
+// so tests can check that no cache goroutines leaked.
+func ActiveCaches() int {
+	return int(activeCaches.Load())
+}
+
+// Size of the buffer holding deferred frequency increments.
+const pendingIncrements = 1024
+
 type EvictionCallback[K comparable, V any] func(key K, value V)
 
 type LFUCache[K comparable, V any] struct {
 	capacity        int
 	size            int
+	highWater       int
+	lowWater        int
 	ttl             time.Duration
 	cleanupInterval time.Duration
+	maxCleanup      int // entries reaped per cleanup tick, 0 for all
+
+	keyMap   map[K]*entry[K, V]
+	freqMap  map[int]*freqList[K, V]
+	minFreq  int
+	tiebreak TiebreakPolicy
+	nextSeq  uint64
+
+	maxBuckets    int
+	maxBucketSize int
+	freqCeiling   int // see WithFrequencyCeiling
+	demoteTo      int
+	trackVictim   func(K) // set while SetReport holds the lock
+	victims       *victimCache[K, V]
+	spill         SpillStore[K, V]   // see WithSpillover
+	spilled       map[K]*entry[K, V] // keys with a copy in spill, without values
+	spillOps      []spillOp[K, V]    // to apply once c.mu is released
+	hot           *hotKeyTracker[K]
+	memLimit      int64 // see WithApproxMemoryLimit
+	memUsed       int64
+	freqDebounce  time.Duration
+	noBumpOnSet   bool
+	compactLow    bool
+	admitColder   bool
+
+	onMinFreq       func(old, new int)
+	observedMinFreq int
+
+	onEmpty          func()
+	onNonEmpty       func()
+	observedNonEmpty bool
+
+	tags       map[string]map[K]struct{} // keys carrying each tag
+	dependents map[K]map[K]struct{}      // keys derived from each key
+
+	mu       rwLocker
+	unlocked bool // see WithoutLocking
+	stop     chan struct{}
+	onEvict  EvictionCallback[K, V]
+
+	clock func() time.Time
+
+	trackContention bool
+	lockWaits       atomic.Int64
+	lockWaitTime    atomic.Int64 // nanoseconds
+
+	hits       counter
+	misses     counter
+	evictions  atomic.Int64
+	rejections atomic.Int64 // see WithAdmitOnlyIfColderVictim
+
+	evictionsByFreq map[int]int64 // capacity evictions by victim frequency
+
+	staleWindow time.Duration
+	maxStale    time.Duration // see WithServeStaleOnError
+	slidingTTL  bool
+	revalidate  func(K) (V, error)
+	refreshMu   sync.Mutex
+	refreshing  map[K]struct{}
+
+	codec Codec[K, V]
+
+	pending chan *entry[K, V] // deferred frequency increments
+
+	coalesceMu   sync.Mutex
+	inflightSets map[K]*pendingSet[V]
+
+	loader    func(K) (V, error)
+	loadMu    sync.Mutex
+	loads     map[K]*call[V]
+	factories map[K]func() V // see SetFactory
+	loadSlots chan struct{}  // see WithMaxConcurrentLoads
+
+	computeTimeout time.Duration // see WithComputeTimeout
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	breakerMu        sync.Mutex
+	loadFailures     int // consecutive loader errors
+	breakerOpenUntil time.Time
+	trialLoad        bool
+
+	copier         func(V) V // see WithValueCopier
+	observeTiming  func(op string, d time.Duration)
+	observeCleanup func(d time.Duration, reaped int)
+
+	sampleRate float64
+	sampleSink func(K)
+
+	decayInterval time.Duration
+	decayFactor   float64
+	windowTick    time.Duration // see WithWindow
+	windowBuckets int
+	windowSlot    int // bucket counting the current accesses
+
+	sizeHint int
+	hasher   func(K) uint64 // shard routing for ShardedCache
+
+	changes        chan<- ChangeEvent[K, V]
+	droppedChanges atomic.Int64
+	entryHasher    func(K, V) uint64 // see WithEntryHasher
 
-	keyMap  map[K]*entry[K, V]
-	freqMap map[int]*freqList[K, V]
-	minFreq int
+	expirations        chan<- K // see WithExpirationChannel
+	droppedExpirations atomic.Int64
 
-	mu      sync.RWMutex
-	stop    chan struct{}
-	onEvict EvictionCallback[K, V]
+	stopped        atomic.Bool
+	strictStop     bool
+	sealed         atomic.Bool // see Seal
+	cleanupRunning atomic.Bool
 
-	hits      atomic.Int64
-	misses    atomic.Int64
-	evictions atomic.Int64
+	deleteOnZero func(V) bool
+	nilCallbacks []string // options given a nil callback, see validate
+
+	utilThreshold float64
+	utilAlert     func(float64)
+	utilAlerted   atomic.Bool
+
+	writer       func(K, V) error
+	writeRetries int
+	writeBackoff time.Duration
+	onWriteError func(K, V, error)
+
+	flushBehind   func([]KeyValue[K, V]) error
+	flushInterval time.Duration
+	flushBatch    int
+	flushNow      chan struct{}
+	flushDone     chan struct{}
+	dirtyMu       sync.Mutex
+	dirty         map[K]V
+	flushClosed   bool // the flush loop has exited, see markDirty
+
+	evictSamples int
+	protectNew   time.Duration
+	policy       Policy[K, V] // see WithPolicy
+
+	evictListener   func(K, V, EvictionReason)
+	observeEvictAge func(time.Duration)
+	logger          func(msg string, kv ...any)
+	evicted         []eviction[K, V] // callbacks to run once c.mu is released
+	autoClose       bool
+	closing         []io.Closer // values to close once c.mu is released
+	callbackWorkers int
+	callbackMu      sync.RWMutex
+	callbackQueue   chan eviction[K, V]
+	callbackLive    atomic.Int32  // workers still running
+	callbackDone    chan struct{} // closed once the last worker exits
+}
+
+// pendingSet is a Set waiting for the lock that later Sets of the same key
+// piggyback on.
+type pendingSet[V any] struct {
+	value V
+	done  chan struct{}
+}
+
+// KeyFreq pairs a key with its access frequency.
+type KeyFreq[K comparable] struct {
+	Key       K
+	Frequency int
+}
+
+// KeyValue is a cached key and its value.
+type KeyValue[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Item is a cached entry as returned by Entries.
+type Item[K comparable, V any] struct {
+	Key       K
+	Value     V
+	Frequency int
 }
 
 type CacheStats struct {
-	Hits      int64
-	Misses    int64
-	Evictions int64
+	Hits       int64
+	Misses     int64
+	Evictions  int64
+	Rejections int64 // new keys not admitted, see WithAdmitOnlyIfColderVictim
 }
 
 // Create a new LFU cache with the given capacity.
@@ -42,94 +230,916 @@ func New[K comparable, V any](
 	ttl time.Duration,
 	cleanupInterval time.Duration,
 	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
+) *LFUCache[K, V] {
+	c := newCache(capacity, ttl, cleanupInterval, onEvict, opts...)
+	c.start()
+	return c
+}
+
+// NewWithError is like New but fails with an error wrapping
+// ErrInvalidConfig when the options don't fit together, for example when
+// an option that needs a callback was given nil, instead of silently
+// dropping data. No goroutines are started in that case.
+func NewWithError[K comparable, V any](
+	capacity int,
+	ttl time.Duration,
+	cleanupInterval time.Duration,
+	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
+) (*LFUCache[K, V], error) {
+	c := newCache(capacity, ttl, cleanupInterval, onEvict, opts...)
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	c.start()
+	return c, nil
+}
+
+// newCache builds a cache from the options without starting any goroutines.
+func newCache[K comparable, V any](
+	capacity int,
+	ttl time.Duration,
+	cleanupInterval time.Duration,
+	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
 ) *LFUCache[K, V] {
 	c := &LFUCache[K, V]{
 		capacity:        capacity,
+		highWater:       capacity,
+		lowWater:        -1,
 		ttl:             ttl,
 		cleanupInterval: cleanupInterval,
-		keyMap:          make(map[K]*entry[K, V]),
 		freqMap:         make(map[int]*freqList[K, V]),
 		stop:            make(chan struct{}), // to gracefully shutdown cleanup routine
 		onEvict:         onEvict,
+		refreshing:      make(map[K]struct{}),
+		codec:           GobCodec[K, V]{},
+		clock:           time.Now,
+		loads:           make(map[K]*call[V]),
+		mu:              &sync.RWMutex{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	// The LFU policy reads this cache's buckets, so each cache needs its own
+	if _, lfu := c.policy.(*lfuPolicy[K, V]); lfu || c.policy == nil {
+		c.policy = &lfuPolicy[K, V]{c: c}
+	}
+	if c.sizeHint <= 0 {
+		c.sizeHint = max(capacity, 0)
+	}
+	c.keyMap = make(map[K]*entry[K, V], c.sizeHint)
+	if c.highWater <= 0 || c.highWater > capacity {
+		c.highWater = capacity
+	}
+	if c.lowWater < 0 || c.lowWater >= c.highWater {
+		c.lowWater = c.highWater - 1
 	}
-	go c.startCleanupLoop()
 	return c
 }
 
+// start launches the background goroutines the options call for.
+func (c *LFUCache[K, V]) start() {
+	activeCaches.Add(1)
+	// Only a backstop: the background goroutines keep the cache reachable,
+	// so the finalizer can only run for caches that have none
+	runtime.SetFinalizer(c, (*LFUCache[K, V]).Stop)
+	if c.callbackWorkers > 0 && c.hasEvictionCallbacks() {
+		c.startCallbackWorkers()
+	}
+	if c.flushBehind != nil {
+		c.startWriteBehind()
+	}
+	// A non-positive interval disables background cleanup; see DrainExpired.
+	// Without locking the loop would race with the caller, so it never runs
+	if c.unlocked {
+		return
+	}
+	if c.cleanupInterval > 0 || c.decayInterval > 0 || c.windowTick > 0 {
+		c.cleanupRunning.Store(c.cleanupInterval > 0)
+		go c.startCleanupLoop()
+	}
+}
+
+// validate reports option combinations that would lose data or do nothing.
+func (c *LFUCache[K, V]) validate() error {
+	if len(c.nilCallbacks) > 0 {
+		return fmt.Errorf("%w: %s given a nil callback", ErrInvalidConfig, c.nilCallbacks[0])
+	}
+	if c.writer == nil && c.flushBehind == nil {
+		if c.writeRetries > 0 {
+			return fmt.Errorf("%w: WithWriteRetry needs WithWriter or WithWriteBehind", ErrInvalidConfig)
+		}
+		if c.onWriteError != nil {
+			return fmt.Errorf("%w: WithWriteErrorHandler needs WithWriter or WithWriteBehind", ErrInvalidConfig)
+		}
+	}
+	return nil
+}
+
+// NewWithCleanup is like New but also returns a function that stops the
+// cache, meant to be deferred. The function may be called more than once.
+func NewWithCleanup[K comparable, V any](
+	capacity int,
+	ttl time.Duration,
+	cleanupInterval time.Duration,
+	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
+) (*LFUCache[K, V], func()) {
+	c := New(capacity, ttl, cleanupInterval, onEvict, opts...)
+	return c, c.Stop
+}
+
+// Stats returns the hit, miss, eviction and rejection counts. The counters
+// are atomic, sharded ones included, so Stats doesn't take the lock.
 func (c *LFUCache[K, V]) Stats() CacheStats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
 	return CacheStats{
-		Hits: c.hits.Load(),
-		Misses: c.misses.Load(),
-		Evictions: c.evictions.Load(),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+		Rejections: c.rejections.Load(),
+	}
+}
+
+// EvictionsByFrequency returns how many entries were evicted for capacity
+// at each frequency. Mostly frequency 1 points to scans of one-off keys,
+// higher frequencies to a cache too small for its working set.
+func (c *LFUCache[K, V]) EvictionsByFrequency() map[int]int64 {
+	c.rlock()
+	defer c.mu.RUnlock()
+	counts := make(map[int]int64, len(c.evictionsByFreq))
+	for freq, n := range c.evictionsByFreq {
+		counts[freq] = n
+	}
+	return counts
+}
+
+// BucketCount returns the number of distinct frequencies in the cache.
+func (c *LFUCache[K, V]) BucketCount() int {
+	c.rlock()
+	defer c.mu.RUnlock()
+	return len(c.freqMap)
+}
+
+// TopN returns the n most frequently used live keys in descending frequency
+// order. Ties are broken by recency. Frequencies are not updated.
+func (c *LFUCache[K, V]) TopN(n int) []KeyFreq[K] {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	if n > c.size {
+		n = c.size
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(freqs)))
+
+	top := make([]KeyFreq[K], 0, n)
+	for _, freq := range freqs {
+		c.freqMap[freq].eachNewest(func(ent *entry[K, V]) bool {
+			if !c.isExpired(ent) {
+				top = append(top, KeyFreq[K]{Key: ent.key, Frequency: freq})
+			}
+			return len(top) < n
+		})
+		if len(top) == n {
+			return top
+		}
+	}
+	return top
+}
+
+// KeysAtFrequency returns the live keys with frequency freq, most recently
+// used first, which is the reverse of their eviction order.
+func (c *LFUCache[K, V]) KeysAtFrequency(freq int) []K {
+	c.rlock()
+	defer c.mu.RUnlock()
+	bucket := c.freqMap[freq]
+	if bucket == nil {
+		return []K{}
+	}
+	keys := make([]K, 0, bucket.len())
+	bucket.eachNewest(func(ent *entry[K, V]) bool {
+		if !c.isExpired(ent) {
+			keys = append(keys, ent.key)
+		}
+		return true
+	})
+	return keys
+}
+
+// Entries returns the live entries sorted by frequency and then by key, so
+// the result is stable across runs, for example for golden-file tests.
+// Keys of string, integer and float kinds are compared by value, other
+// keys by their fmt representation. Frequencies are not updated.
+func (c *LFUCache[K, V]) Entries() []Item[K, V] {
+	c.rlock()
+	items := make([]Item[K, V], 0, c.size)
+	for key, ent := range c.keyMap {
+		if !c.isExpired(ent) {
+			items = append(items, Item[K, V]{Key: key, Value: ent.value, Frequency: ent.frequency})
+		}
+	}
+	c.mu.RUnlock()
+	if c.copier != nil {
+		for i := range items {
+			items[i].Value = c.copier(items[i].Value)
+		}
 	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Frequency != items[j].Frequency {
+			return items[i].Frequency < items[j].Frequency
+		}
+		return lessKey(items[i].Key, items[j].Key)
+	})
+	return items
+}
+
+// SampleByFrequency picks a random live entry with probability
+// proportional to its frequency, or to 1/frequency when inverse is set, for
+// example to pick a likely hot key to prefetch or a likely cold one to
+// shed. It makes one pass over the entries and returns false when there
+// are none. Frequencies are not updated.
+func (c *LFUCache[K, V]) SampleByFrequency(inverse bool) (K, V, bool) {
+	key, value, ok := c.sampleByFrequency(inverse)
+	if !ok {
+		return key, value, false
+	}
+	return key, c.copyOut(value), true
+}
+
+func (c *LFUCache[K, V]) sampleByFrequency(inverse bool) (K, V, bool) {
+	c.rlock()
+	defer c.mu.RUnlock()
+	var picked *entry[K, V]
+	total := 0.0
+	for _, ent := range c.keyMap {
+		if c.isExpired(ent) {
+			continue
+		}
+		weight := float64(ent.frequency)
+		if inverse {
+			weight = 1 / weight
+		}
+		// Keep each entry with probability weight/total so far
+		total += weight
+		if rand.Float64()*total < weight {
+			picked = ent
+		}
+	}
+	if picked == nil {
+		var key K
+		var value V
+		return key, value, false
+	}
+	return picked.key, picked.value, true
+}
+
+// lessKey orders keys by value when their kind is ordered, falling back to
+// comparing their fmt representations.
+func lessKey[K comparable](a, b K) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsValid() && vb.IsValid() && va.Kind() == vb.Kind() {
+		switch va.Kind() {
+		case reflect.String:
+			return va.String() < vb.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return va.Int() < vb.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return va.Uint() < vb.Uint()
+		case reflect.Float32, reflect.Float64:
+			return va.Float() < vb.Float()
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
 }
 
 // Retrieve a value and update its frequency.
+// Loader errors are dropped; use GetE to observe them.
 func (c *LFUCache[K, V]) Get(key K) (V, bool) {
-	c.mu.RLock()
+	value, ok, _ := c.GetE(key)
+	return value, ok
+}
+
+// GetOrDefault returns the cached value for key, or def on a miss.
+// The default is not stored.
+func (c *LFUCache[K, V]) GetOrDefault(key K, def V) V {
+	if value, ok := c.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// copyOut returns value as handed out to callers, copied by the copier
+// of WithValueCopier if there is one.
+func (c *LFUCache[K, V]) copyOut(value V) V {
+	if c.copier == nil {
+		return value
+	}
+	return c.copier(value)
+}
+
+// lookup retrieves a cached value and updates its frequency.
+func (c *LFUCache[K, V]) lookup(key K) (V, bool) {
+	if c.sampleSink != nil && rand.Float64() < c.sampleRate {
+		c.sampleSink(key)
+	}
+	if c.hot != nil {
+		c.hot.count(key, c.clock())
+	}
+	c.rlock()
 	ent, ok := c.keyMap[key]
+	var overdue time.Duration
+	var value V
+	if ok {
+		overdue = c.overdue(ent, c.clock())
+		value = ent.value
+	}
 	c.mu.RUnlock()
 
-	// Remove expired key if spotted to complement the CleanUpLoop
-	if !ok || time.Since(ent.createdAt) > c.ttl {
-		if ok {
-			c.mu.Lock()
-			c.deleteKey(key, ent) // Still O(1), so wouldn't hurt performance much
-			c.mu.Unlock()
+	if c.sealed.Load() {
+		if !ok || overdue > 0 {
+			c.misses.Add(1)
+			var zero V
+			return zero, false
+		}
+		c.hits.Add(1)
+		return value, true
+	}
+
+	// Serve a stale entry while it is refreshed in the background
+	if ok && overdue > 0 && c.revalidate != nil && overdue <= c.staleWindow {
+		value, found := c.touch(key, ent)
+		if found {
+			c.revalidateAsync(key)
+			c.hits.Add(1)
+			return value, true
+		}
+		ok = false
+	}
+
+	if !ok && c.victims != nil {
+		if value, found := c.promoteVictim(key); found {
+			c.hits.Add(1)
+			return value, true
+		}
+	}
+	if !ok && c.spill != nil {
+		if value, found := c.promoteSpilled(key); found {
+			c.hits.Add(1)
+			return value, true
+		}
+	}
+
+	// Remove expired key if spotted to complement the CleanUpLoop, unless
+	// it may still be served should a reload fail
+	if !ok || overdue > 0 {
+		if ok && overdue > c.maxStale {
+			c.lock()
+			if c.keyMap[key] == ent {
+				c.deleteKey(key, ent) // Still O(1), so wouldn't hurt performance much
+			}
+			c.unlock()
 		}
 		c.misses.Add(1)
 		var zero V
 		return zero, false
 	}
 
-	c.mu.Lock()
-	c.increment(ent)
-	c.mu.Unlock()
+	if c.pending != nil {
+		c.deferIncrement(ent)
+		c.hits.Add(1)
+		return value, true
+	}
+
+	value, found := c.touch(key, ent)
+	if !found {
+		c.misses.Add(1)
+		return value, false
+	}
 	c.hits.Add(1)
+	return value, true
+}
+
+// deferIncrement queues a frequency bump to be applied under the write lock
+// later. When the queue is full it is drained inline if the lock is free,
+// otherwise the bump is dropped rather than blocking the reader.
+func (c *LFUCache[K, V]) deferIncrement(ent *entry[K, V]) {
+	select {
+	case c.pending <- ent:
+	default:
+		if c.mu.TryLock() {
+			c.drainPending()
+			c.unlock()
+		}
+	}
+}
+
+// drainPending applies queued frequency bumps. Must be called with c.mu held.
+func (c *LFUCache[K, V]) drainPending() {
+	for {
+		select {
+		case ent := <-c.pending:
+			// Skip entries removed since they were read
+			if c.keyMap[ent.key] == ent {
+				ent.accessCount++
+				c.increment(ent)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// GetBatch looks up each key in order, returning the values and a found
+// flag per index. Missing keys yield the zero value.
+func (c *LFUCache[K, V]) GetBatch(keys []K) ([]V, []bool) {
+	values := make([]V, len(keys))
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = c.Get(key)
+	}
+	return values, found
+}
+
+// touch bumps the frequency of ent if it is still the live entry for key.
+func (c *LFUCache[K, V]) touch(key K, ent *entry[K, V]) (V, bool) {
+	c.lock()
+	defer c.unlock()
+	if c.keyMap[key] != ent {
+		var zero V
+		return zero, false
+	}
+	ent.accessCount++
+	c.increment(ent)
 	return ent.value, true
 }
 
+// revalidateAsync reloads key in the background unless a reload is already running.
+func (c *LFUCache[K, V]) revalidateAsync(key K) {
+	c.refreshMu.Lock()
+	if _, ok := c.refreshing[key]; ok {
+		c.refreshMu.Unlock()
+		return
+	}
+	c.refreshing[key] = struct{}{}
+	c.refreshMu.Unlock()
+
+	refresh := func() {
+		defer func() {
+			c.refreshMu.Lock()
+			delete(c.refreshing, key)
+			c.refreshMu.Unlock()
+		}()
+		value, err := c.revalidate(key)
+		if err != nil {
+			return
+		}
+		c.lock()
+		defer c.unlock()
+		// Don't resurrect entries that were removed while loading
+		if ent, ok := c.keyMap[key]; ok {
+			c.invalidateDependents(key)
+			ent.value = value
+			ent.createdAt = c.clock()
+			ent.expiresAt = time.Time{}
+			c.emit(OpSet, ent)
+			if c.memLimit > 0 {
+				c.weigh(ent)
+				c.fitMemory()
+			}
+		}
+	}
+	// Without locking the refresh must not race with the caller
+	if c.unlocked {
+		refresh()
+		return
+	}
+	go refresh()
+}
+
+// TrySet stores key like Set if it can get the lock within timeout, for
+// callers that would rather skip caching than wait, for example behind a
+// long cleanup pass. It reports whether the value was stored.
+func (c *LFUCache[K, V]) TrySet(key K, value V, timeout time.Duration) bool {
+	stored, err := c.write(key, value, func(remove bool) bool {
+		if !c.lockWithin(timeout) {
+			return false
+		}
+		defer c.unlock()
+		if remove {
+			c.del(key)
+			return true
+		}
+		if c.sealed.Load() {
+			return false
+		}
+		c.set(key, value)
+		return true
+	})
+	return stored && err == nil
+}
+
+// SetReport is like Set but reports whether making room for key evicted an
+// entry, and which one. When the low-water mark makes several entries go
+// at once, the first victim is reported. evictedKey is the zero value when
+// evicted is false.
+func (c *LFUCache[K, V]) SetReport(key K, value V) (evicted bool, evictedKey K) {
+	c.write(key, value, func(remove bool) bool {
+		if !c.lockUnsealed() {
+			return false
+		}
+		defer c.unlock()
+		if remove {
+			c.del(key)
+			return true
+		}
+		c.trackVictim = func(k K) {
+			if !evicted {
+				evicted, evictedKey = true, k
+			}
+		}
+		c.set(key, value)
+		c.trackVictim = nil
+		return true
+	})
+	return evicted, evictedKey
+}
+
+// WouldEvict reports whether a Set of key would have to evict an entry
+// to make room, that is whether the cache is full and key is not cached.
+// It doesn't change the cache.
+func (c *LFUCache[K, V]) WouldEvict(key K) bool {
+	c.rlock()
+	defer c.mu.RUnlock()
+	if c.capacity == 0 || c.size < c.highWater {
+		return false
+	}
+	_, ok := c.keyMap[key]
+	return !ok
+}
+
+// Compute atomically replaces the value of key with the result of fn,
+// which receives the current value and whether key was live. If fn returns
+// keep=false the key is deleted, otherwise the new value is stored like a
+// Set. Compute returns the new value and keep. fn runs under the write lock
+// and must not call back into the cache.
+func (c *LFUCache[K, V]) Compute(key K, fn func(old V, exists bool) (V, bool)) (V, bool) {
+	if !c.lockUnsealed() {
+		var zero V
+		return zero, false
+	}
+	defer c.unlock()
+	ent, exists := c.keyMap[key]
+	if exists && c.isExpired(ent) {
+		c.deleteKey(key, ent)
+		exists = false
+	}
+	var old V
+	if exists {
+		old = ent.value
+	}
+	value, keep := fn(old, exists)
+	if !keep {
+		c.del(key)
+		return value, false
+	}
+	c.set(key, value)
+	return value, true
+}
+
+// UpdateAll calls fn for each live entry under the write lock. If fn
+// returns keep, the entry's value is replaced with the returned one
+// without changing its frequency or expiry; otherwise the entry is
+// deleted. fn must not call back into the cache.
+func (c *LFUCache[K, V]) UpdateAll(fn func(K, V) (V, bool)) {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	now := c.clock()
+	for key, ent := range c.keyMap {
+		if c.overdue(ent, now) > 0 {
+			continue
+		}
+		value, keep := fn(key, ent.value)
+		if !keep {
+			c.del(key)
+			continue
+		}
+		c.invalidateDependents(key)
+		ent.value = value
+		c.emit(OpSet, ent)
+		if c.memLimit > 0 {
+			c.weigh(ent)
+		}
+	}
+	if c.memLimit > 0 {
+		c.fitMemory()
+	}
+}
+
+// SetWithDeadline inserts or updates key so that it expires at deadline
+// instead of after the cache TTL. A later Set of the key reverts it to the
+// TTL. A deadline that has already passed removes key like Delete instead
+// of storing the value. Errors are dropped like with Set.
+func (c *LFUCache[K, V]) SetWithDeadline(key K, value V, deadline time.Time) {
+	if !deadline.After(c.clock()) {
+		c.Delete(key)
+		return
+	}
+	_ = c.writeLocked(key, value, func() {
+		c.setExpiring(key, value, deadline)
+	})
+}
+
 // Insert or update a key-value pair.
+// Errors are dropped; use SetWithError to observe them.
 func (c *LFUCache[K, V]) Set(key K, value V) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	_ = c.SetWithError(key, value)
+}
+
+// SetWithError is like Set but reports why a value was not stored: either
+// ErrCacheStopped when the cache was stopped in strict mode, or the error
+// of the write-through writer once its retries are exhausted.
+func (c *LFUCache[K, V]) SetWithError(key K, value V) error {
+	_, err := c.write(key, value, func(remove bool) bool {
+		switch {
+		case remove:
+			c.Delete(key)
+		case c.inflightSets != nil:
+			c.coalescedSet(key, value)
+		default:
+			c.lock()
+			c.set(key, value)
+			c.unlock()
+		}
+		return true
+	})
+	return err
+}
+
+// write takes a Set of key through the steps shared by all Set variants:
+// it honors WithDeleteOnZero, writes through the writer, times the store,
+// queues the value for write-behind and checks utilization. store updates
+// the cache, deleting key instead if remove is set, and reports whether it
+// did; if it didn't, only the writer has seen the value.
+func (c *LFUCache[K, V]) write(key K, value V, store func(remove bool) bool) (bool, error) {
+	if c.rejectStopped() {
+		return false, ErrCacheStopped
+	}
+	if c.sealed.Load() {
+		return false, ErrCacheSealed
+	}
+	if c.deleteOnZero != nil && c.deleteOnZero(value) {
+		return store(true), nil
+	}
+	if c.writer != nil {
+		if err := c.writeThrough(key, value); err != nil {
+			return false, err
+		}
+	}
+	if c.observeTiming != nil {
+		start := time.Now()
+		defer func() { c.observeTiming("set", time.Since(start)) }()
+	}
+	if !store(false) {
+		return false, nil
+	}
+	if c.flushBehind != nil {
+		c.markDirty(key, value)
+	}
+	if c.utilAlert != nil {
+		c.checkUtilization()
+	}
+	return true, nil
+}
+
+// writeLocked is write with a store that runs fn under the write lock, or
+// deletes key instead when WithDeleteOnZero applies.
+func (c *LFUCache[K, V]) writeLocked(key K, value V, fn func()) error {
+	_, err := c.write(key, value, func(remove bool) bool {
+		if !c.lockUnsealed() {
+			return false
+		}
+		defer c.unlock()
+		if remove {
+			c.del(key)
+		} else {
+			fn()
+		}
+		return true
+	})
+	return err
+}
+
+// checkUtilization fires the utilization alert when the fill ratio rises
+// to the threshold, and re-arms it once the ratio drops below again.
+func (c *LFUCache[K, V]) checkUtilization() {
+	c.rlock()
+	size, capacity := c.size, c.capacity
+	c.mu.RUnlock()
+	if capacity <= 0 {
+		return
+	}
+	util := float64(size) / float64(capacity)
+	if util < c.utilThreshold {
+		c.utilAlerted.Store(false)
+	} else if c.utilAlerted.CompareAndSwap(false, true) {
+		c.utilAlert(util)
+	}
+}
+
+// coalescedSet lets concurrent Sets of the same key share one lock
+// acquisition. Callers arriving while a Set of the key is waiting for the
+// lock hand over their value and wait for it to be applied; the latest
+// value wins.
+func (c *LFUCache[K, V]) coalescedSet(key K, value V) {
+	c.coalesceMu.Lock()
+	if p, ok := c.inflightSets[key]; ok {
+		p.value = value
+		c.coalesceMu.Unlock()
+		<-p.done
+		return
+	}
+	p := &pendingSet[V]{value: value, done: make(chan struct{})}
+	c.inflightSets[key] = p
+	c.coalesceMu.Unlock()
 
+	c.lock()
+	c.coalesceMu.Lock()
+	delete(c.inflightSets, key)
+	value = p.value
+	c.coalesceMu.Unlock()
+	c.set(key, value)
+	c.unlock()
+	close(p.done)
+}
+
+// set inserts or updates key. Must be called with c.mu held.
+func (c *LFUCache[K, V]) set(key K, value V) {
+	c.setExpiring(key, value, time.Time{})
+}
+
+// setExpiring is set with a deadline, or the TTL if expiresAt is zero.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) setExpiring(key K, value V, expiresAt time.Time) {
+	if c.sealed.Load() {
+		return
+	}
+	c.beforeSet(key)
 	if c.capacity == 0 {
 		return
 	}
 
 	if ent, ok := c.keyMap[key]; ok {
 		ent.value = value
-		ent.createdAt = time.Now()
-		c.increment(ent)
+		ent.createdAt = c.clock()
+		ent.expiresAt = expiresAt
+		if !c.noBumpOnSet {
+			c.increment(ent)
+		}
+		c.emit(OpSet, ent)
+		if c.memLimit > 0 {
+			c.weigh(ent)
+			c.fitMemory()
+		}
 		return
 	}
 
-	if c.size >= c.capacity {
-		c.evict()
+	if c.admitColder && !c.admits() {
+		c.rejections.Add(1)
+		return
 	}
-
+	c.makeRoom()
 	ent := &entry[K, V]{
-		key:       key,
-		value:     value,
-		frequency: 1,
-		createdAt: time.Now(),
+		key:         key,
+		value:       value,
+		frequency:   1,
+		createdAt:   c.clock(),
+		expiresAt:   expiresAt,
+		seq:         c.nextSeq,
+		decayWeight: 1,
+	}
+	ent.lastAccess = ent.createdAt
+	c.nextSeq++
+	c.insert(ent)
+	if c.keyMap[key] != ent {
+		return // too large for the memory limit, evicted at once
 	}
-	c.keyMap[key] = ent
+	c.emit(OpSet, ent)
+}
 
-	if c.freqMap[1] == nil {
-		c.freqMap[1] = newFreqList[K, V]()
+// beforeSet drops what a new value of key replaces: an in-flight load, a
+// registered factory and the entries derived from the old value. Must be
+// called with c.mu held.
+func (c *LFUCache[K, V]) beforeSet(key K) {
+	c.supersedeLoad(key)
+	if c.factories != nil {
+		delete(c.factories, key)
 	}
-	c.freqMap[1].pushFront(ent)
-	c.minFreq = 1
-	c.size++
+	c.invalidateDependents(key)
+}
+
+// ReserveTransient raises the capacity by n until the returned release
+// function is called, so a burst of short-lived inserts doesn't push out
+// the hot set. Release restores the capacity, evicting the least frequently
+// used entries if the cache is over it; calling it again has no effect.
+// Reservations may overlap.
+func (c *LFUCache[K, V]) ReserveTransient(n int) func() {
+	if !c.lockUnsealed() {
+		return func() {}
+	}
+	c.resize(n)
+	c.unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.lock()
+			defer c.unlock()
+			c.resize(-n)
+			if c.sealed.Load() {
+				return // keep the entries, over capacity or not
+			}
+			c.drainPending()
+			for c.size > c.highWater && c.evict() {
+			}
+		})
+	}
+}
+
+// resize shifts the capacity and water marks by delta. Must be called with
+// c.mu held.
+func (c *LFUCache[K, V]) resize(delta int) {
+	c.capacity += delta
+	c.highWater += delta
+	c.lowWater += delta
+}
+
+// newBucket creates the list for entries of frequency freq.
+func (c *LFUCache[K, V]) newBucket(freq int) *freqList[K, V] {
+	if c.compactLow && freq == 1 {
+		return newCompactFreqList[K, V]()
+	}
+	return newFreqList[K, V]()
+}
+
+// makeRoom evicts down to the low-water mark once the high-water mark is
+// reached.
+func (c *LFUCache[K, V]) makeRoom() {
+	if c.size >= c.highWater {
+		c.drainPending() // make sure victims are chosen on current frequencies
+		for c.size > c.lowWater && c.evict() {
+		}
+	}
+}
+
+// admits reports whether a new key may be inserted under
+// WithAdmitOnlyIfColderVictim: either there is room, or the entry that
+// would be evicted has not been accessed since it was inserted.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) admits() bool {
+	if c.size < c.highWater || !c.evictsByFrequency() {
+		return true
+	}
+	c.drainPending()
+	if c.freqMap[c.minFreq] == nil {
+		c.resetMinFreq()
+	}
+	return c.minFreq <= 1
 }
 
 func (c *LFUCache[K, V]) increment(ent *entry[K, V]) {
+	if c.slidingTTL {
+		ent.touchedAt = c.clock()
+	}
+	c.policy.Access(ent.key)
+	if c.freqDebounce > 0 {
+		now := c.clock()
+		if now.Sub(ent.lastAccess) < c.freqDebounce {
+			c.freqMap[ent.frequency].moveToFront(ent)
+			return
+		}
+		ent.lastAccess = now
+	}
+
+	if c.windowBuckets > 0 {
+		c.windowOf(ent)[c.windowSlot]++
+	}
 	oldFreq := ent.frequency
-	ent.frequency++
+	if c.freqCeiling > 0 && oldFreq >= c.freqCeiling {
+		ent.frequency = c.demoteTo
+	} else {
+		ent.frequency++
+	}
 
 	// Remove from old freq list
 	c.freqMap[oldFreq].remove(ent)
@@ -142,37 +1152,242 @@ func (c *LFUCache[K, V]) increment(ent *entry[K, V]) {
 
 	// Add to new freq list
 	if c.freqMap[ent.frequency] == nil {
-		c.freqMap[ent.frequency] = newFreqList[K, V]()
+		c.freqMap[ent.frequency] = c.newBucket(ent.frequency)
 	}
 	c.freqMap[ent.frequency].pushFront(ent)
+	if ent.frequency < c.minFreq {
+		c.minFreq = ent.frequency // demoted by the ceiling
+	}
+	if c.maxBucketSize > 0 {
+		c.trimBucket(ent.frequency)
+	}
+	if c.maxBuckets > 0 {
+		c.limitBuckets()
+	}
+}
+
+// evict removes the least frequently used entry and reports whether one was found.
+func (c *LFUCache[K, V]) evict() bool {
+	victim := c.policyVictim()
+	if victim == nil {
+		return false
+	}
+	c.evictEntry(victim)
+	return true
+}
+
+// evictEntry removes victim as a capacity eviction.
+func (c *LFUCache[K, V]) evictEntry(victim *entry[K, V]) {
+	c.unlink(victim)
+	c.evictions.Add(1)
+	if c.evictionsByFreq == nil {
+		c.evictionsByFreq = make(map[int]int64)
+	}
+	c.evictionsByFreq[victim.frequency]++
+	c.emit(OpEvict, victim)
+	c.queueEvicted(victim, ReasonCapacity)
+	if c.trackVictim != nil {
+		c.trackVictim(victim.key)
+	}
+	if c.victims != nil {
+		if dropped := c.victims.add(victim); dropped != nil {
+			c.queueClose(dropped.value)
+			c.unpark(dropped)
+		}
+	}
+	if c.spill != nil {
+		c.spillEvicted(victim)
+	}
+	if victim.dependsOn != nil && c.isParked(victim.key) {
+		c.indexDependencies(victim) // so changing a dependency drops the copy
+	}
 }
 
-func (c *LFUCache[K, V]) evict() {
+// trimBucket evicts the least recently used entries of the bucket for freq
+// while it holds more than maxBucketSize entries.
+func (c *LFUCache[K, V]) trimBucket(freq int) {
+	for list := c.freqMap[freq]; list != nil && list.len() > c.maxBucketSize; list = c.freqMap[freq] {
+		c.evictEntry(list.victim(TiebreakLRU))
+	}
+}
+
+// exactVictim picks the victim from the lowest frequency bucket, moving on
+// to higher buckets while candidates are still protected.
+func (c *LFUCache[K, V]) exactVictim() *entry[K, V] {
 	list := c.freqMap[c.minFreq]
 	if list == nil {
-		return
+		// minFreq may be stale after deletions, so find the lowest bucket
+		c.resetMinFreq()
+		if list = c.freqMap[c.minFreq]; list == nil {
+			return nil
+		}
+	}
+	victim := list.victim(c.tiebreak)
+	if !c.isProtected(victim) {
+		return victim
+	}
+
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	// Fall back to the oldest entry if every candidate is protected
+	oldest := victim
+	for _, freq := range freqs {
+		var unprotected *entry[K, V]
+		c.freqMap[freq].eachOldest(func(ent *entry[K, V]) bool {
+			if !c.isProtected(ent) {
+				unprotected = ent
+				return false
+			}
+			if ent.createdAt.Before(oldest.createdAt) {
+				oldest = ent
+			}
+			return true
+		})
+		if unprotected != nil {
+			return unprotected
+		}
 	}
-	evicted := list.removeOldest()
-	if evicted != nil {
-		delete(c.keyMap, evicted.key)
-		c.size--
-		c.evictions.Add(1)
-		if list.isEmpty() {
-			delete(c.freqMap, c.minFreq)
+	return oldest
+}
+
+// sampleVictim approximates LFU like Redis does: it looks at evictSamples
+// entries in map iteration order, which Go randomizes, and picks the least
+// frequently used one, preferring the earliest inserted on ties. Protected
+// entries are only picked if the whole sample is protected.
+func (c *LFUCache[K, V]) sampleVictim() *entry[K, V] {
+	var victim, oldest *entry[K, V]
+	n := 0
+	for _, ent := range c.keyMap {
+		if c.isProtected(ent) {
+			if oldest == nil || ent.createdAt.Before(oldest.createdAt) {
+				oldest = ent
+			}
+		} else if victim == nil || ent.frequency < victim.frequency ||
+			(ent.frequency == victim.frequency && ent.seq < victim.seq) {
+			victim = ent
 		}
-		if c.onEvict != nil {
-			c.onEvict(evicted.key, evicted.value)
+		if n++; n == c.evictSamples {
+			break
+		}
+	}
+	if victim == nil {
+		return oldest
+	}
+	return victim
+}
+
+// isProtected reports whether ent is too new to be evicted.
+func (c *LFUCache[K, V]) isProtected(ent *entry[K, V]) bool {
+	return c.protectNew > 0 && c.clock().Sub(ent.createdAt) < c.protectNew
+}
+
+// resetMinFreq points minFreq at the lowest populated frequency bucket.
+func (c *LFUCache[K, V]) resetMinFreq() {
+	c.minFreq = 0
+	for freq := range c.freqMap {
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
 		}
 	}
 }
 
 func (c *LFUCache[K, V]) Len() int {
-	c.mu.RLock()
+	c.rlock()
 	defer c.mu.RUnlock()
 	return c.size
 }
 
+// ShrinkToFit copies the entries into freshly allocated maps sized for the
+// current count, so memory held by maps that grew during a usage spike can
+// be released. Entries, frequencies and recency are unchanged. It is O(n)
+// under the write lock.
+func (c *LFUCache[K, V]) ShrinkToFit() {
+	c.lock()
+	defer c.unlock()
+	keyMap := make(map[K]*entry[K, V], len(c.keyMap))
+	for key, ent := range c.keyMap {
+		keyMap[key] = ent
+	}
+	freqMap := make(map[int]*freqList[K, V], len(c.freqMap))
+	for freq, list := range c.freqMap {
+		freqMap[freq] = list
+	}
+	c.keyMap, c.freqMap = keyMap, freqMap
+}
+
+// isExpired reports whether ent has outlived its TTL or deadline.
+func (c *LFUCache[K, V]) isExpired(ent *entry[K, V]) bool {
+	return c.overdue(ent, c.clock()) > 0
+}
+
+// overdue returns how long ago ent expired at now, or a non-positive
+// duration if it is still live.
+func (c *LFUCache[K, V]) overdue(ent *entry[K, V], now time.Time) time.Duration {
+	if !ent.expiresAt.IsZero() {
+		return now.Sub(ent.expiresAt)
+	}
+	if c.slidingTTL && ent.touchedAt.After(ent.createdAt) {
+		return now.Sub(ent.touchedAt) - c.ttl
+	}
+	return now.Sub(ent.createdAt) - c.ttl
+}
+
 func (c *LFUCache[K, V]) deleteKey(key K, ent *entry[K, V]) {
+	c.unlink(ent)
+	c.evictions.Add(1)
+	c.emit(OpExpire, ent)
+	c.queueEvicted(ent, ReasonExpired)
+	if c.expirations != nil {
+		select {
+		case c.expirations <- key:
+		default:
+			c.droppedExpirations.Add(1)
+		}
+	}
+}
+
+// insert links ent into keyMap and the bucket for its frequency.
+func (c *LFUCache[K, V]) insert(ent *entry[K, V]) {
+	c.keyMap[ent.key] = ent
+	c.dropVictim(ent.key)
+	c.policy.Add(ent.key, ent.value)
+	if ent.tags != nil {
+		c.indexTags(ent)
+	}
+	if ent.dependsOn != nil {
+		c.indexDependencies(ent)
+	}
+	if c.freqMap[ent.frequency] == nil {
+		c.freqMap[ent.frequency] = c.newBucket(ent.frequency)
+	}
+	c.freqMap[ent.frequency].pushFront(ent)
+	c.size++
+	if c.memLimit > 0 {
+		ent.memSize = 0
+		c.weigh(ent)
+	}
+	if c.maxBucketSize > 0 {
+		c.trimBucket(ent.frequency)
+	}
+	if c.freqMap[c.minFreq] == nil {
+		c.resetMinFreq()
+	} else if ent.frequency < c.minFreq {
+		c.minFreq = ent.frequency
+	}
+	if c.maxBuckets > 0 {
+		c.limitBuckets()
+	}
+	if c.memLimit > 0 {
+		c.fitMemory()
+	}
+}
+
+// unlink removes ent from the cache without counting an eviction.
+func (c *LFUCache[K, V]) unlink(ent *entry[K, V]) {
 	c.freqMap[ent.frequency].remove(ent)
 	if c.freqMap[ent.frequency].isEmpty() {
 		delete(c.freqMap, ent.frequency)
@@ -180,39 +1395,349 @@ func (c *LFUCache[K, V]) deleteKey(key K, ent *entry[K, V]) {
 			c.minFreq++
 		}
 	}
-	delete(c.keyMap, key)
+	delete(c.keyMap, ent.key)
+	c.policy.Remove(ent.key)
 	c.size--
-	c.evictions.Add(1)
-	if c.onEvict != nil {
-		c.onEvict(ent.key, ent.value)
+	c.memUsed -= ent.memSize
+	if ent.tags != nil {
+		c.untag(ent)
+	}
+	if ent.dependsOn != nil {
+		c.undepend(ent)
 	}
 }
 
+// Take removes key and returns its value in one step, so no other caller
+// can read it afterwards. It is not counted as an eviction and does not
+// invoke the eviction callback.
+func (c *LFUCache[K, V]) Take(key K) (V, bool) {
+	if !c.lockUnsealed() {
+		var zero V
+		return zero, false
+	}
+	defer c.unlock()
+	c.dropVictim(key)
+	c.invalidateDependents(key)
+	ent, ok := c.keyMap[key]
+	if !ok || c.isExpired(ent) {
+		var zero V
+		return zero, false
+	}
+	c.unlink(ent)
+	c.emit(OpDelete, ent)
+	return ent.value, true
+}
+
+// Rename moves the entry for oldKey to newKey, keeping its frequency,
+// creation time and eviction position, and reports whether oldKey was
+// live. An entry already stored under newKey is dropped as if deleted,
+// along with its dependents, and the renamed value wins over a load of
+// newKey in flight, as with a Set. Entries depending on oldKey then depend
+// on newKey.
+func (c *LFUCache[K, V]) Rename(oldKey, newKey K) bool {
+	if !c.lockUnsealed() {
+		return false
+	}
+	defer c.unlock()
+	ent, ok := c.keyMap[oldKey]
+	if !ok || c.isExpired(ent) {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	c.del(newKey) // also invalidates whatever depends on newKey
+	if c.keyMap[oldKey] != ent {
+		return false // oldKey itself depended on newKey
+	}
+	c.beforeSet(newKey) // the renamed value wins over a load of newKey
+
+	c.untag(ent)
+	c.undepend(ent)
+	c.emit(OpDelete, ent)
+	delete(c.keyMap, oldKey)
+	ent.key = newKey
+	c.keyMap[newKey] = ent
+	c.policy.Remove(oldKey)
+	c.policy.Add(newKey, ent.value)
+	c.indexTags(ent)
+	c.indexDependencies(ent)
+	c.moveDependents(oldKey, newKey)
+	c.emit(OpSet, ent)
+	return true
+}
+
+// Delete removes key and reports whether it was present. It is not counted
+// as an eviction and does not invoke the eviction callback.
+func (c *LFUCache[K, V]) Delete(key K) bool {
+	if !c.lockUnsealed() {
+		return false
+	}
+	defer c.unlock()
+	return c.del(key)
+}
+
+// del removes key like Delete. Must be called with c.mu held.
+func (c *LFUCache[K, V]) del(key K) bool {
+	if c.sealed.Load() {
+		return false
+	}
+	c.dropVictim(key)
+	c.invalidateDependents(key)
+	ent, ok := c.keyMap[key]
+	if !ok {
+		return false
+	}
+	c.unlink(ent)
+	c.emit(OpDelete, ent)
+	c.queueClose(ent.value)
+	return true
+}
+
 func (c *LFUCache[K, V]) startCleanupLoop() {
-	ticker := time.NewTicker(c.cleanupInterval)
+	defer c.cleanupRunning.Store(false)
+	var cleanup, decay <-chan time.Time
+	if c.cleanupInterval > 0 {
+		ticker := time.NewTicker(c.cleanupInterval)
+		defer ticker.Stop()
+		cleanup = ticker.C
+	}
+	if c.decayInterval > 0 {
+		ticker := time.NewTicker(c.decayInterval)
+		defer ticker.Stop()
+		decay = ticker.C
+	}
+	var window <-chan time.Time
+	if c.windowTick > 0 {
+		ticker := time.NewTicker(c.windowTick)
+		defer ticker.Stop()
+		window = ticker.C
+	}
 	for {
 		select {
-		case <-ticker.C:
+		case <-cleanup:
 			c.cleanupExpired()
+		case <-decay:
+			c.decayFrequencies()
+		case <-window:
+			c.rotateWindow()
 		case <-c.stop:
-			ticker.Stop()
 			return
 		}
 	}
 }
 
 func (c *LFUCache[K, V]) cleanupExpired() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	now := time.Now()
+	if !c.lockUnsealed() {
+		return
+	}
+	start := time.Now()
+	c.drainPending()
+	reaped := c.removeExpired(c.maxCleanup)
+	d := time.Since(start)
+	c.unlock()
+	if c.observeCleanup != nil {
+		c.observeCleanup(d, reaped)
+	}
+}
+
+// DrainExpired removes up to max expired entries and returns how many were
+// removed, removing all of them when max <= 0. It lets callers that disable
+// the cleanup loop reap expired entries at points of their choosing.
+func (c *LFUCache[K, V]) DrainExpired(max int) int {
+	if !c.lockUnsealed() {
+		return 0
+	}
+	defer c.unlock()
+	return c.removeExpired(max)
+}
+
+// retention returns how long past their TTL expired entries are kept
+// before being reaped.
+func (c *LFUCache[K, V]) retention() time.Duration {
+	return max(c.staleWindow, c.maxStale)
+}
+
+func (c *LFUCache[K, V]) removeExpired(max int) int {
+	now := c.clock()
+	removed := 0
 	for k, ent := range c.keyMap {
-		if now.Sub(ent.createdAt) > c.ttl {
+		if max > 0 && removed >= max {
+			break
+		}
+		// Stale entries are kept around until their revalidation window closes
+		if c.overdue(ent, now) > c.retention() {
 			c.deleteKey(k, ent)
+			removed++
+		}
+	}
+	return removed
+}
+
+// AccessCount returns how many times key was read since it was inserted.
+// Unlike its frequency, the count never decays, merges or caps. With
+// WithDeferredIncrements it lags like the frequency does.
+func (c *LFUCache[K, V]) AccessCount(key K) (int64, bool) {
+	c.rlock()
+	defer c.mu.RUnlock()
+	ent, ok := c.keyMap[key]
+	if !ok || c.isExpired(ent) {
+		return 0, false
+	}
+	return ent.accessCount, true
+}
+
+// ExpiredCount returns how many expired entries are still waiting to be
+// reaped. It scans every entry, so it is O(n) and meant for monitoring.
+func (c *LFUCache[K, V]) ExpiredCount() int {
+	c.rlock()
+	defer c.mu.RUnlock()
+	now := c.clock()
+	count := 0
+	for _, ent := range c.keyMap {
+		if c.overdue(ent, now) > c.retention() {
+			count++
 		}
 	}
+	return count
 }
 
-// Stop terminates the cleanup loop goroutine.
+// DroppedExpirations returns how many expired keys were not sent because
+// the channel of WithExpirationChannel was full.
+func (c *LFUCache[K, V]) DroppedExpirations() int64 {
+	return c.droppedExpirations.Load()
+}
+
+// Refresh restarts the TTL of key without updating its frequency and
+// reports whether it was live. An entry with a deadline keeps it.
+func (c *LFUCache[K, V]) Refresh(key K) bool {
+	if !c.lockUnsealed() {
+		return false
+	}
+	defer c.unlock()
+	ent, ok := c.keyMap[key]
+	if !ok || c.isExpired(ent) {
+		return false
+	}
+	if ent.expiresAt.IsZero() {
+		ent.createdAt = c.clock()
+		c.emit(OpSet, ent)
+	}
+	return true
+}
+
+// GetAndRefresh reads key like Get and, if it is live, extends its
+// lifetime to newTTL from now, or restarts the default TTL when newTTL is
+// 0, all under one write lock. A sealed cache always misses.
+func (c *LFUCache[K, V]) GetAndRefresh(key K, newTTL time.Duration) (V, bool) {
+	value, ok := c.getAndRefresh(key, newTTL)
+	if !ok {
+		c.misses.Add(1)
+		return value, false
+	}
+	c.hits.Add(1)
+	return c.copyOut(value), true
+}
+
+func (c *LFUCache[K, V]) getAndRefresh(key K, newTTL time.Duration) (V, bool) {
+	var zero V
+	if !c.lockUnsealed() {
+		return zero, false
+	}
+	defer c.unlock()
+	ent, ok := c.keyMap[key]
+	if ok && c.isExpired(ent) {
+		c.deleteKey(key, ent)
+		ok = false
+	}
+	if !ok {
+		return zero, false
+	}
+	now := c.clock()
+	if newTTL > 0 {
+		ent.expiresAt = now.Add(newTTL)
+	} else {
+		ent.expiresAt = time.Time{}
+		ent.createdAt = now
+	}
+	ent.accessCount++
+	c.increment(ent)
+	c.emit(OpSet, ent)
+	return ent.value, true
+}
+
+// RefreshFunc restarts the TTL of every live entry for which pred returns
+// true, under a single write lock, and returns how many were refreshed.
+// Frequencies are not touched. Entries set with SetWithDeadline keep their
+// deadline and are not counted.
+func (c *LFUCache[K, V]) RefreshFunc(pred func(K, V) bool) int {
+	if !c.lockUnsealed() {
+		return 0
+	}
+	defer c.unlock()
+	now := c.clock()
+	refreshed := 0
+	for key, ent := range c.keyMap {
+		if !ent.expiresAt.IsZero() || c.overdue(ent, now) > 0 || !pred(key, ent.value) {
+			continue
+		}
+		ent.createdAt = now
+		c.emit(OpSet, ent)
+		refreshed++
+	}
+	return refreshed
+}
+
+// AgeRange returns the oldest and newest creation times among stored
+// entries, with ok false when the cache is empty. Expired entries that have
+// not been reaped yet are included, so an oldest time more than the TTL ago
+// means cleanup is falling behind.
+func (c *LFUCache[K, V]) AgeRange() (oldest, newest time.Time, ok bool) {
+	c.rlock()
+	defer c.mu.RUnlock()
+	for _, ent := range c.keyMap {
+		if !ok || ent.createdAt.Before(oldest) {
+			oldest = ent.createdAt
+		}
+		if !ok || ent.createdAt.After(newest) {
+			newest = ent.createdAt
+		}
+		ok = true
+	}
+	return oldest, newest, ok
+}
+
+// Stop terminates the cleanup loop goroutine, flushes pending write-behind
+// updates and waits for queued eviction callbacks to finish. It is safe to
+// call more than once.
+//
+// Always call Stop when done with a cache. A finalizer stops caches that
+// are garbage collected, but caches with background goroutines never are.
+//
+// A stopped cache keeps serving Get and Set, but expired entries are no
+// longer reaped in the background: they are only removed when Get runs into
+// them or by DrainExpired. Use WithStrictStop to reject operations instead.
 func (c *LFUCache[K, V]) Stop() {
-	close(c.stop)
+	if c.stopped.CompareAndSwap(false, true) {
+		close(c.stop)
+		c.cleanupRunning.Store(false)
+		activeCaches.Add(-1)
+		runtime.SetFinalizer(c, nil)
+	}
+	if c.flushDone != nil {
+		<-c.flushDone
+	}
+	c.stopCallbackWorkers()
+}
+
+// CleanupRunning reports whether the background loop is reaping expired
+// entries. It is false after Stop or when the cleanup interval is not positive.
+func (c *LFUCache[K, V]) CleanupRunning() bool {
+	return c.cleanupRunning.Load()
+}
+
+// rejectStopped reports whether operations must fail because the cache was
+// stopped in strict mode.
+func (c *LFUCache[K, V]) rejectStopped() bool {
+	return c.strictStop && c.stopped.Load()
 }
@@ -1,8 +1,12 @@
 package lfu
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,8 +16,9 @@ func newTestCache[K comparable, V any](
 	cap int,
 	ttl time.Duration,
 	evictCb EvictionCallback[K, V],
+	opts ...Option[K, V],
 ) *LFUCache[K, V] {
-	return New(cap, ttl, 50*time.Millisecond, evictCb)
+	return New(cap, ttl, 50*time.Millisecond, evictCb, opts...)
 }
 
 // Test basic Set and Get
@@ -107,43 +112,1232 @@ func TestCleanupLoop(t *testing.T) {
 
 // Test eviction callback on expiration
 func TestEvictionCallback(t *testing.T) {
-	var called bool
+	called := make(chan struct{}, 1)
 	cache := newTestCache(1, 50*time.Millisecond, func(k string, v int) {
-		called = true
+		called <- struct{}{}
 	})
 
 	cache.Set("x", 1)
 	time.Sleep(100 * time.Millisecond)
-	_, _ = cache.Get("x") // triggers deleteKey()
+	_, _ = cache.Get("x") // triggers deleteKey(), unless the cleanup loop got there first
 
-	if !called {
+	select {
+	case <-called:
+	case <-time.After(time.Second):
 		t.Errorf("Expected eviction callback to be called")
 	}
 }
 
+// Test evictions are counted by the victim's frequency
+func TestEvictionsByFrequency(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	for i := 0; i < 3; i++ {
+		cache.Set(fmt.Sprint("scan", i), i) // each evicts the previous one
+	}
+	cache.Get("scan2")
+	cache.Get("scan2")
+	cache.Get("scan2")
+	cache.Set("b", 2) // evicts a at frequency 3
+
+	want := map[int]int64{1: 2, 3: 1}
+	if got := cache.EvictionsByFrequency(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
 func TestCacheStats(t *testing.T) {
 	cache := newTestCache[string, int](2, time.Minute, nil)
 
 	cache.Set("a", 1)
 	cache.Set("b", 2)
 
-	_, _ = cache.Get("a")
-	_, _ = cache.Get("b")
+	_, _ = cache.Get("a")
+	_, _ = cache.Get("b")
+
+	_, _ = cache.Get("c")
+
+	cache.Set("c", 3)
+
+	stats := cache.Stats()
+
+	if stats.Hits != 2 {
+		t.Errorf("Expected 2 hits, got %d", stats.Hits)
+	}
+	if stats.Misses != 1 {
+		t.Errorf("Expected 1 miss, got %d", stats.Misses)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+}
+
+// Test Stats sums the sharded counters after concurrent Gets
+func TestShardedCountersStats(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithShardedCounters[string, int](8))
+	cache.Set("a", 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 1000; n++ {
+				cache.Get("a")
+				cache.Get("missing")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits != 8000 || stats.Misses != 8000 {
+		t.Errorf("Expected 8000 hits and misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		cache.Stats()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected Stats not to wait for the lock")
+	}
+}
+
+// Test stale entries are served while being refreshed in the background
+func TestStaleWhileRevalidate(t *testing.T) {
+	start := time.Now()
+	var elapsed atomic.Int64
+	clock := func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+	var loads atomic.Int32
+	loading, release := make(chan struct{}, 1), make(chan struct{})
+	loader := func(k string) (int, error) {
+		loads.Add(1)
+		loading <- struct{}{}
+		<-release
+		return 2, nil
+	}
+	changes := make(chan ChangeEvent[string, int], 8)
+	cache := New(2, 50*time.Millisecond, 0, nil, WithClock[string, int](clock),
+		WithStaleWhileRevalidate[string, int](200*time.Millisecond, loader),
+		WithChangeFeed[string, int](changes))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	<-changes
+	elapsed.Store(int64(70 * time.Millisecond))
+
+	// Within the stale window: stale value is a hit and a single refresh runs
+	for i := 0; i < 3; i++ {
+		if v, ok := cache.Get("a"); !ok || v != 1 {
+			t.Errorf("Expected stale a=1, got %v (found=%v)", v, ok)
+		}
+	}
+	select {
+	case <-loading:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a refresh to start")
+	}
+	close(release)
+	select {
+	case ev := <-changes:
+		if ev.Op != OpSet || ev.Value != 2 {
+			t.Fatalf("Expected the refresh to set a=2, got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the refresh to finish")
+	}
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected 1 refresh, got %d", n)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 2 {
+		t.Errorf("Expected refreshed a=2, got %v", v)
+	}
+	if stats := cache.Stats(); stats.Hits != 4 {
+		t.Errorf("Expected 4 hits, got %d", stats.Hits)
+	}
+}
+
+// Test entries past the stale window are hard misses
+func TestStaleWindowExpiry(t *testing.T) {
+	loader := func(k string) (int, error) { return 2, nil }
+	cache := newTestCache[string, int](2, 30*time.Millisecond, nil,
+		WithStaleWhileRevalidate[string, int](30*time.Millisecond, loader))
+
+	cache.Set("a", 1)
+	time.Sleep(80 * time.Millisecond)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to be expired past the stale window")
+	}
+}
+
+// Test TopN returns the hottest keys in descending frequency
+func TestTopN(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	for i := 0; i < 3; i++ {
+		cache.Get("b")
+	}
+	cache.Get("c")
+
+	top := cache.TopN(2)
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(top))
+	}
+	if top[0].Key != "b" || top[0].Frequency != 4 {
+		t.Errorf("Expected b with frequency 4 first, got %+v", top[0])
+	}
+	if top[1].Key != "c" || top[1].Frequency != 2 {
+		t.Errorf("Expected c with frequency 2 second, got %+v", top[1])
+	}
+
+	if all := cache.TopN(10); len(all) != 3 {
+		t.Errorf("Expected all 3 entries, got %d", len(all))
+	}
+}
+
+// Test KeysAtFrequency lists each bucket most recently used first
+func TestKeysAtFrequency(t *testing.T) {
+	cache := newTestCache[string, int](8, time.Minute, nil)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		cache.Set(key, 1)
+	}
+	cache.Get("b")
+	cache.Get("d")
+	cache.Get("d")
+	cache.Get("e")
+
+	for freq, want := range map[int]string{1: "[c a]", 2: "[e b]", 3: "[d]", 4: "[]"} {
+		if keys := cache.KeysAtFrequency(freq); keys == nil || fmt.Sprint(keys) != want {
+			t.Errorf("Expected %s at frequency %d, got %v", want, freq, keys)
+		}
+	}
+}
+
+// Test eviction drains down to the low-water mark once the high mark is hit
+func TestWaterMarks(t *testing.T) {
+	var evicted int
+	cache := newTestCache(10, time.Minute, func(k int, v int) { evicted++ },
+		WithHighWaterMark[int, int](8), WithLowWaterMark[int, int](4))
+
+	for i := 0; i < 8; i++ {
+		cache.Set(i, i)
+	}
+	if evicted != 0 {
+		t.Errorf("Expected no evictions below the high mark, got %d", evicted)
+	}
+
+	cache.Set(8, 8) // reaching the high mark drains to the low mark first
+	if evicted != 4 {
+		t.Errorf("Expected 4 evictions, got %d", evicted)
+	}
+	if cache.Len() != 5 {
+		t.Errorf("Expected length 5, got %d", cache.Len())
+	}
+}
+
+// Test ExpiredCount reports expired entries the cleanup loop hasn't reaped
+func TestExpiredCount(t *testing.T) {
+	cache := New[string, int](3, 30*time.Millisecond, time.Hour, nil)
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if n := cache.ExpiredCount(); n != 0 {
+		t.Errorf("Expected 0 expired entries, got %d", n)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	cache.Set("c", 3)
+
+	if n := cache.ExpiredCount(); n != 2 {
+		t.Errorf("Expected 2 expired entries, got %d", n)
+	}
+}
+
+// Test deferred increments still drive eviction order
+func TestDeferredIncrements(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithDeferredIncrements[string, int](true))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1, got %v", v)
+	}
+
+	cache.Set("c", 3) // pending bumps are applied before choosing a victim
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Expected a to remain")
+	}
+}
+
+// Test GetBatch preserves key order and flags misses
+func TestGetBatch(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("c", 3)
+
+	values, found := cache.GetBatch([]string{"c", "b", "a"})
+
+	wantValues := []int{3, 0, 1}
+	wantFound := []bool{true, false, true}
+	for i := range wantValues {
+		if values[i] != wantValues[i] || found[i] != wantFound[i] {
+			t.Errorf("Index %d: expected (%d, %v), got (%d, %v)",
+				i, wantValues[i], wantFound[i], values[i], found[i])
+		}
+	}
+}
+
+// fillPeers leaves a and b at frequency 2, with b inserted last but
+// accessed least recently.
+func fillPeers(cache *LFUCache[string, int]) {
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Get("a")
+}
+
+// Test LRU tiebreak evicts the least recently used peer
+func TestTiebreakLRU(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithTiebreak[string, int](TiebreakLRU))
+	fillPeers(cache)
+
+	cache.Set("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+}
+
+// Test FIFO tiebreak evicts the earliest inserted peer
+func TestTiebreakFIFO(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithTiebreak[string, int](TiebreakFIFO))
+	fillPeers(cache)
+
+	cache.Set("c", 3)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to be evicted")
+	}
+}
+
+// Test random tiebreak picks victims among same-frequency peers
+func TestTiebreakRandom(t *testing.T) {
+	victims := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		cache := newTestCache(3, time.Minute, func(k string, v int) {
+			victims[k] = true
+		}, WithTiebreak[string, int](TiebreakRandom))
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Set("c", 3)
+		cache.Set("d", 4)
+		cache.Stop()
+	}
+
+	if victims["d"] {
+		t.Errorf("Expected newly inserted d never to be evicted")
+	}
+	if len(victims) < 2 {
+		t.Errorf("Expected several distinct victims, got %v", victims)
+	}
+}
+
+// Test concurrent Sets of one key collapse into a single update
+func TestSetCoalescing(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithSetCoalescing[string, int]())
+
+	cache.mu.Lock() // hold the lock so the Sets pile up behind it
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache.Set("hot", i)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cache.mu.Unlock()
+	wg.Wait()
+
+	top := cache.TopN(1)
+	if len(top) != 1 || top[0].Frequency != 1 {
+		t.Errorf("Expected a single coalesced insert, got %+v", top)
+	}
+}
+
+// Test DrainExpired reaps in bounded batches with the cleanup loop disabled
+func TestDrainExpired(t *testing.T) {
+	cache := New[int, int](10, 20*time.Millisecond, 0, nil)
+	defer cache.Stop()
+
+	for i := 0; i < 5; i++ {
+		cache.Set(i, i)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	if n := cache.DrainExpired(3); n != 3 {
+		t.Errorf("Expected 3 entries drained, got %d", n)
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected 2 entries left, got %d", n)
+	}
+	if n := cache.DrainExpired(0); n != 2 {
+		t.Errorf("Expected remaining 2 entries drained, got %d", n)
+	}
+}
+
+// Test the timing observer sees every Get and Set
+func TestTimingObserver(t *testing.T) {
+	ops := make(map[string]int)
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithTimingObserver[string, int](func(op string, d time.Duration) {
+			if d < 0 {
+				t.Errorf("Expected non-negative duration for %s, got %v", op, d)
+			}
+			ops[op]++
+		}))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Get("c")
+
+	if ops["set"] != 2 || ops["get"] != 2 {
+		t.Errorf("Expected 2 sets and 2 gets, got %v", ops)
+	}
+}
+
+// Test Take returns the value once and removes it without evicting
+func TestTake(t *testing.T) {
+	var evicted int
+	cache := newTestCache(2, time.Minute, func(k string, v int) { evicted++ })
+	cache.Set("token", 7)
+
+	if v, ok := cache.Take("token"); !ok || v != 7 {
+		t.Errorf("Expected token=7, got %v", v)
+	}
+	if _, ok := cache.Take("token"); ok {
+		t.Errorf("Expected token to be consumed")
+	}
+	if _, ok := cache.Get("token"); ok {
+		t.Errorf("Expected token to be gone")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected empty cache, got length %d", cache.Len())
+	}
+	if stats := cache.Stats(); evicted != 0 || stats.Evictions != 0 {
+		t.Errorf("Expected no evictions, got %d (callback %d)", stats.Evictions, evicted)
+	}
+}
+
+// Test Delete removes a key without counting an eviction
+func TestDelete(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+
+	if !cache.Delete("a") {
+		t.Errorf("Expected a to be deleted")
+	}
+	if cache.Delete("a") {
+		t.Errorf("Expected second delete to report a missing key")
+	}
+	if cache.Len() != 0 || cache.Stats().Evictions != 0 {
+		t.Errorf("Expected empty cache and no evictions, got %d / %d",
+			cache.Len(), cache.Stats().Evictions)
+	}
+}
+
+// Test a stopped cache keeps serving but only expires entries lazily
+func TestStoppedCache(t *testing.T) {
+	cache := newTestCache[string, int](2, 30*time.Millisecond, nil)
+	cache.Set("a", 1)
+	cache.Stop()
+	cache.Stop() // idempotent
+
+	cache.Set("b", 2)
+	if v, ok := cache.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 after Stop, got %v", v)
+	}
+	if err := cache.SetWithError("c", 3); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected no background reaping after Stop, got length %d", n)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to expire lazily on Get")
+	}
+}
+
+// Test strict mode rejects operations after Stop
+func TestStrictStop(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithStrictStop[string, int]())
+	cache.Set("a", 1)
+	cache.Stop()
+
+	if _, ok, err := cache.GetE("a"); ok || !errors.Is(err, ErrCacheStopped) {
+		t.Errorf("Expected ErrCacheStopped from GetE, got (%v, %v)", ok, err)
+	}
+	if err := cache.SetWithError("b", 2); !errors.Is(err, ErrCacheStopped) {
+		t.Errorf("Expected ErrCacheStopped from SetWithError, got %v", err)
+	}
+	cache.Set("c", 3)
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected Sets to be dropped, got length %d", n)
+	}
+}
+
+// Test sampled eviction removes the coldest entry when sampling everything
+func TestSampledEviction(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil,
+		WithSampledEviction[string, int](3))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+	cache.Get("c")
+
+	cache.Set("d", 4)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if cache.Len() != 3 {
+		t.Errorf("Expected length 3, got %d", cache.Len())
+	}
+}
+
+// Test GetOrDefault falls back without storing the default
+func TestGetOrDefault(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+
+	if v := cache.GetOrDefault("a", 9); v != 1 {
+		t.Errorf("Expected a=1, got %d", v)
+	}
+	if v := cache.GetOrDefault("b", 9); v != 9 {
+		t.Errorf("Expected default 9, got %d", v)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected default not to be stored, got length %d", cache.Len())
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+// Test a just-inserted key survives an immediate capacity-triggering Set
+func TestProtectNew(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithProtectNewFor[string, int](50*time.Millisecond))
+
+	cache.Set("old", 1)
+	cache.Get("old")
+	time.Sleep(60 * time.Millisecond)
+
+	cache.Set("new", 2)
+	cache.Set("newer", 3) // new is the LFU but still protected
+
+	if _, ok := cache.Get("new"); !ok {
+		t.Errorf("Expected protected key new to survive")
+	}
+	if _, ok := cache.Get("old"); ok {
+		t.Errorf("Expected unprotected key old to be evicted")
+	}
+
+	cache.Set("newest", 4) // everything is protected: evict the oldest
+	if _, ok := cache.Get("new"); ok {
+		t.Errorf("Expected oldest protected key new to be evicted")
+	}
+}
+
+// Test CleanupRunning follows the cleanup loop lifecycle
+func TestCleanupRunning(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	if !cache.CleanupRunning() {
+		t.Errorf("Expected cleanup loop to be running")
+	}
+	cache.Stop()
+	if cache.CleanupRunning() {
+		t.Errorf("Expected cleanup loop to stop")
+	}
+
+	disabled := New[string, int](2, time.Minute, 0, nil)
+	defer disabled.Stop()
+	if disabled.CleanupRunning() {
+		t.Errorf("Expected no cleanup loop with a zero interval")
+	}
+}
+
+// Test setting an empty value deletes the key
+func TestDeleteOnZero(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithDeleteOnZero[string, int](func(v int) bool { return v == 0 }))
+
+	cache.Set("a", 1)
+	cache.Set("a", 0)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to be deleted by a zero Set")
+	}
+
+	cache.Set("b", 0)
+	if cache.Len() != 0 {
+		t.Errorf("Expected zero value not to be stored, got length %d", cache.Len())
+	}
+}
+
+// Test the cleanup func returned by NewWithCleanup stops the cache
+func TestNewWithCleanup(t *testing.T) {
+	cache, cleanup := NewWithCleanup[string, int](2, time.Minute, time.Minute, nil)
+	cache.Set("a", 1)
+
+	cleanup()
+	cleanup() // idempotent
+
+	if cache.CleanupRunning() {
+		t.Errorf("Expected cleanup to stop the cache")
+	}
+}
+
+// Test contention tracking records waits for a held lock
+func TestContentionTracking(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithContentionTracking[string, int]())
+
+	cache.Set("a", 1)
+	if waits, _ := cache.ContentionStats(); waits != 0 {
+		t.Errorf("Expected no waits without contention, got %d", waits)
+	}
+
+	cache.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		cache.Set("b", 2)
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	cache.mu.Unlock()
+	<-done
+
+	waits, total := cache.ContentionStats()
+	if waits != 1 || total < 20*time.Millisecond {
+		t.Errorf("Expected 1 wait of at least 20ms, got %d totalling %v", waits, total)
+	}
+}
+
+// Test creation times keep the monotonic clock reading
+func TestMonotonicCreatedAt(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+
+	cache.mu.RLock()
+	createdAt := cache.keyMap["a"].createdAt
+	cache.mu.RUnlock()
+	if !strings.Contains(createdAt.String(), "m=") {
+		t.Errorf("Expected createdAt to carry a monotonic reading, got %v", createdAt)
+	}
+}
+
+// Test a backward clock jump neither expires entries en masse nor keeps
+// them alive past their TTL once time moves on
+func TestClockJumpBackward(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	setClock := func(t time.Time) {
+		mu.Lock()
+		now = t
+		mu.Unlock()
+	}
+	start := now
+	cache := New(2, time.Minute, 0, nil, WithClock[string, int](clock))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	setClock(start.Add(-time.Hour))
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Expected a to survive a backward clock jump")
+	}
+
+	setClock(start.Add(2 * time.Minute))
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to expire once its TTL has passed")
+	}
+}
+
+// Test the utilization alert fires once per threshold crossing
+func TestUtilizationAlert(t *testing.T) {
+	var alerts []float64
+	cache := newTestCache[int, int](4, time.Minute, nil,
+		WithUtilizationAlert[int, int](0.75, func(util float64) {
+			alerts = append(alerts, util)
+		}))
+
+	for i := 0; i < 10; i++ {
+		cache.Set(i, i)
+	}
+	if len(alerts) != 1 || alerts[0] != 0.75 {
+		t.Errorf("Expected a single alert at 0.75, got %v", alerts)
+	}
+
+	for i := 0; i < 10; i++ {
+		cache.Delete(i)
+	}
+	cache.Set(1, 1) // below the threshold, re-arms the alert
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+	if len(alerts) != 2 {
+		t.Errorf("Expected the alert to fire again after re-arming, got %v", alerts)
+	}
+}
+
+// Test AgeRange reports the oldest and newest creation times
+func TestAgeRange(t *testing.T) {
+	now := time.Now()
+	cache := New(3, time.Minute, 0, nil, WithClock[string, int](func() time.Time { return now }))
+	defer cache.Stop()
+
+	if _, _, ok := cache.AgeRange(); ok {
+		t.Errorf("Expected ok=false for an empty cache")
+	}
+
+	start := now
+	cache.Set("a", 1)
+	now = start.Add(time.Second)
+	cache.Set("b", 2)
+	now = start.Add(2 * time.Second)
+	cache.Set("c", 3)
+
+	oldest, newest, ok := cache.AgeRange()
+	if !ok || !oldest.Equal(start) || !newest.Equal(now) {
+		t.Errorf("Expected range [%v, %v], got [%v, %v]", start, now, oldest, newest)
+	}
+}
+
+// Test SetWithDeadline expires entries at an absolute time
+func TestSetWithDeadline(t *testing.T) {
+	now := time.Now()
+	cache := New(3, time.Hour, 0, nil, WithClock[string, int](func() time.Time { return now }))
+	defer cache.Stop()
+
+	start := now
+	cache.SetWithDeadline("token", 1, start.Add(time.Minute))
+	now = start.Add(30 * time.Second)
+	if _, ok := cache.Get("token"); !ok {
+		t.Errorf("Expected token to be live before its deadline")
+	}
+	now = start.Add(2 * time.Minute)
+	if _, ok := cache.Get("token"); ok {
+		t.Errorf("Expected token to expire at its deadline despite the longer TTL")
+	}
+
+	cache.Set("old", 1)
+	cache.SetWithDeadline("old", 2, now.Add(-time.Second))
+	if _, ok := cache.Get("old"); ok {
+		t.Errorf("Expected a past deadline to remove the key")
+	}
+	cache.SetWithDeadline("past", 1, now)
+	if cache.Len() != 0 {
+		t.Errorf("Expected nothing stored for past deadlines, got %d entries", cache.Len())
+	}
+
+	cache.SetWithDeadline("reset", 1, now.Add(time.Second))
+	cache.Set("reset", 2)
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("reset"); !ok {
+		t.Errorf("Expected Set to revert the key to the TTL")
+	}
+	if n := cache.DrainExpired(0); n != 0 {
+		t.Errorf("Expected nothing to drain, got %d", n)
+	}
+}
+
+// Test RefreshFunc restarts the TTL of matching entries only
+func TestRefreshFunc(t *testing.T) {
+	now := time.Now()
+	cache := New(4, time.Minute, 0, nil, WithClock[string, int](func() time.Time { return now }))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.SetWithDeadline("d", 4, now.Add(90*time.Second))
+
+	now = now.Add(50 * time.Second)
+	n := cache.RefreshFunc(func(k string, v int) bool { return v%2 == 0 || k == "a" })
+	if n != 2 {
+		t.Errorf("Expected 2 entries refreshed, got %d", n)
+	}
+	if !cache.Refresh("c") || cache.Refresh("missing") {
+		t.Errorf("Expected Refresh to report only live keys")
+	}
+
+	now = now.Add(50 * time.Second)
+	for _, k := range []string{"a", "b", "c"} {
+		if _, ok := cache.Get(k); !ok {
+			t.Errorf("Expected %s to survive after being refreshed", k)
+		}
+	}
+	if _, ok := cache.Get("d"); ok {
+		t.Errorf("Expected d to keep its deadline")
+	}
+	if f := frequencyOf(cache, "c"); f != 2 {
+		t.Errorf("Expected refreshing not to bump frequency, got %d", f)
+	}
+}
+
+// Test the access sampler sees roughly rate of all lookups
+func TestAccessSampler(t *testing.T) {
+	sampled := 0
+	cache := newTestCache[int, int](10, time.Minute, nil,
+		WithAccessSampler[int, int](0.1, func(int) { sampled++ }))
+	cache.Set(1, 1)
+
+	const gets = 20000
+	for i := 0; i < gets; i++ {
+		cache.Get(i % 2)
+	}
+	if sampled < gets*8/100 || sampled > gets*12/100 {
+		t.Errorf("Expected about %d sampled keys, got %d", gets/10, sampled)
+	}
+}
+
+// Test ShrinkToFit keeps the remaining entries intact
+func TestShrinkToFit(t *testing.T) {
+	cache := newTestCache[int, int](10000, time.Minute, nil)
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	for i := 10; i < 10000; i++ {
+		cache.Delete(i)
+	}
+	cache.Get(3)
+
+	cache.ShrinkToFit()
+
+	if cache.Len() != 10 {
+		t.Errorf("Expected 10 entries, got %d", cache.Len())
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := cache.Peek(i); !ok || v != i {
+			t.Errorf("Expected %d=%d after shrinking, got %v", i, i, v)
+		}
+	}
+	if f := frequencyOf(cache, 3); f != 2 {
+		t.Errorf("Expected frequency 2 to be kept, got %d", f)
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
+
+// Test Entries orders by frequency and then key
+func TestEntries(t *testing.T) {
+	cache := newTestCache[int, string](5, time.Minute, nil)
+	for _, k := range []int{10, 2, 9, 1} {
+		cache.Set(k, fmt.Sprint(k))
+	}
+	cache.Get(9)
+	cache.Get(1)
+
+	want := []Item[int, string]{
+		{Key: 2, Value: "2", Frequency: 1},
+		{Key: 10, Value: "10", Frequency: 1},
+		{Key: 1, Value: "1", Frequency: 2},
+		{Key: 9, Value: "9", Frequency: 2},
+	}
+	if got := cache.Entries(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// Test SetReport names the entry evicted to make room
+func TestSetReport(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	if evicted, k := cache.SetReport("a", 1); evicted || k != "" {
+		t.Errorf("Expected no eviction, got %q", k)
+	}
+	cache.SetReport("b", 2)
+	cache.Get("b")
+	if evicted, _ := cache.SetReport("b", 3); evicted {
+		t.Errorf("Expected updating a key not to evict")
+	}
+	if evicted, k := cache.SetReport("c", 3); !evicted || k != "a" {
+		t.Errorf("Expected a to be evicted, got %v %q", evicted, k)
+	}
+}
+
+// Test WouldEvict only reports new keys on a full cache
+func TestWouldEvict(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+	if cache.WouldEvict("b") {
+		t.Errorf("Expected no eviction below capacity")
+	}
+	cache.Set("b", 2)
+	if !cache.WouldEvict("c") {
+		t.Errorf("Expected a new key to evict at capacity")
+	}
+	if cache.WouldEvict("a") {
+		t.Errorf("Expected an existing key not to evict")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected WouldEvict to leave 2 entries, got %d", n)
+	}
+}
+
+// Test Compute performs atomic read-modify-write and deletes on keep=false
+func TestCompute(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	add := func(old int, exists bool) (int, bool) { return old + 1, true }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Compute("n", add)
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("n"); v != 50 {
+		t.Errorf("Expected 50 atomic increments, got %d", v)
+	}
+
+	v, kept := cache.Compute("n", func(old int, exists bool) (int, bool) {
+		return 0, !exists
+	})
+	if kept || v != 0 || cache.Contains("n") {
+		t.Errorf("Expected keep=false to delete n")
+	}
+	if _, kept := cache.Compute("new", func(old int, exists bool) (int, bool) {
+		return 7, !exists
+	}); !kept || !cache.Contains("new") {
+		t.Errorf("Expected a missing key to be stored")
+	}
+}
+
+// Test UpdateAll replaces values in place and deletes the rejected entries
+func TestUpdateAll(t *testing.T) {
+	cache := newTestCache[int, int](8, time.Minute, nil)
+	for i := 1; i <= 6; i++ {
+		cache.Set(i, i)
+		for j := 0; j < i%3; j++ {
+			cache.Get(i)
+		}
+	}
+
+	cache.UpdateAll(func(key, value int) (int, bool) {
+		return value * 10, key%2 == 0
+	})
+
+	if n := cache.Len(); n != 3 {
+		t.Errorf("Expected 3 entries, got %d", n)
+	}
+	for i := 1; i <= 6; i++ {
+		v, ok := cache.Peek(i)
+		if i%2 == 1 && ok {
+			t.Errorf("Expected %d to be deleted", i)
+		}
+		if i%2 == 0 && (!ok || v != i*10) {
+			t.Errorf("Expected (%d, true) for %d, got (%d, %v)", i*10, i, v, ok)
+		}
+		if i%2 == 0 && frequencyOf(cache, i) != 1+i%3 {
+			t.Errorf("Expected %d to keep frequency %d, got %d", i, 1+i%3, frequencyOf(cache, i))
+		}
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected consistent buckets, got %v", err)
+	}
+}
+
+// Test WithMaxCleanupPerTick bounds the work of each cleanup tick
+func TestMaxCleanupPerTick(t *testing.T) {
+	now := time.Now()
+	cache := New(20, time.Second, 0, nil,
+		WithClock[int, int](func() time.Time { return now }),
+		WithMaxCleanupPerTick[int, int](3))
+	defer cache.Stop()
+	for i := 0; i < 10; i++ {
+		cache.Set(i, i)
+	}
+	now = now.Add(time.Minute)
+
+	for _, want := range []int{7, 4, 1, 0} {
+		cache.cleanupExpired()
+		if n := cache.Len(); n != want {
+			t.Errorf("Expected %d entries left after the tick, got %d", want, n)
+		}
+	}
+}
+
+// Test the cleanup observer reports the entries each pass reaped
+func TestCleanupObserver(t *testing.T) {
+	var reaped atomic.Int64
+	cache := newTestCache[int, int](200, 10*time.Millisecond, nil,
+		WithCleanupObserver[int, int](func(d time.Duration, n int) {
+			if d < 0 {
+				t.Errorf("Expected a non-negative duration, got %v", d)
+			}
+			reaped.Add(int64(n))
+		}))
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for reaped.Load() < 100 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := reaped.Load(); n != 100 {
+		t.Errorf("Expected 100 reaped entries, got %d", n)
+	}
+}
+
+// Test ActiveCaches counts caches until they are stopped
+func TestActiveCaches(t *testing.T) {
+	before := ActiveCaches()
+	caches := []*LFUCache[string, int]{
+		New[string, int](1, time.Minute, time.Minute, nil),
+		New[string, int](1, time.Minute, 0, nil),
+		New(1, time.Minute, 0, nil, WithAsyncCallbacks[string, int](2), WithEvictionListener(func(string, int, EvictionReason) {})),
+	}
+	if n := ActiveCaches(); n != before+3 {
+		t.Errorf("Expected %d active caches, got %d", before+3, n)
+	}
+	for _, c := range caches {
+		c.Stop()
+		c.Stop()
+	}
+	if n := ActiveCaches(); n != before {
+		t.Errorf("Expected %d active caches after Stop, got %d", before, n)
+	}
+}
+
+// Test SampleByFrequency weighs entries by frequency or its inverse
+func TestSampleByFrequency(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	if _, _, ok := cache.SampleByFrequency(false); ok {
+		t.Errorf("Expected no sample from an empty cache")
+	}
+	cache.Set("cold", 1)
+	cache.Set("hot", 2)
+	cache.Get("hot")
+	cache.Get("hot") // frequency 3
+
+	const samples = 10000
+	for _, inverse := range []bool{false, true} {
+		hot := 0
+		for i := 0; i < samples; i++ {
+			if k, _, _ := cache.SampleByFrequency(inverse); k == "hot" {
+				hot++
+			}
+		}
+		want := 0.75
+		if inverse {
+			want = 0.25
+		}
+		if got := float64(hot) / samples; got < want-0.03 || got > want+0.03 {
+			t.Errorf("Expected hot sampled about %.2f of the time (inverse=%v), got %.3f", want, inverse, got)
+		}
+	}
+}
+
+// Test SampleByFrequency hands out a copy with WithValueCopier
+func TestSampleByFrequencyCopies(t *testing.T) {
+	cache := newTestCache[string, []int](2, time.Minute, nil,
+		WithValueCopier[string, []int](func(v []int) []int { return append([]int(nil), v...) }))
+	cache.Set("a", []int{1})
+	_, v, _ := cache.SampleByFrequency(false)
+	v[0] = 99
+	if v, _ := cache.Get("a"); v[0] != 1 {
+		t.Errorf("Expected the cached value to stay [1], got %v", v)
+	}
+}
+
+// Test WithNoFreqBumpOnSet keeps write-only keys evictable
+func TestNoFreqBumpOnSet(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithNoFreqBumpOnSet[string, int](true))
+	cache.Set("written", 1)
+	cache.Set("read", 2)
+	cache.Get("read")
+	for i := 0; i < 10; i++ {
+		cache.Set("written", i)
+	}
+	cache.Set("new", 3)
+
+	if cache.Contains("written") {
+		t.Errorf("Expected the write-only key to be evicted")
+	}
+	if !cache.Contains("read") {
+		t.Errorf("Expected the read key to survive")
+	}
+}
+
+// Test Rename moves an entry and keeps its frequency
+func TestRename(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("tmp", 1)
+	cache.Get("tmp")
+
+	if !cache.Rename("tmp", "perm") {
+		t.Errorf("Expected Rename to find tmp")
+	}
+	if cache.Contains("tmp") {
+		t.Errorf("Expected tmp to be gone")
+	}
+	if v, ok := cache.Peek("perm"); !ok || v != 1 {
+		t.Errorf("Expected perm=1, got %v", v)
+	}
+	if f := frequencyOf(cache, "perm"); f != 2 {
+		t.Errorf("Expected frequency 2 to be kept, got %d", f)
+	}
+	if cache.Rename("missing", "x") {
+		t.Errorf("Expected Rename of a missing key to fail")
+	}
+}
+
+// Test Rename overwrites an existing target key
+func TestRenameOverwrite(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	cache.Rename("a", "b")
 
-	_, _ = cache.Get("c")
+	if v, _ := cache.Peek("b"); v != 1 || cache.Len() != 1 {
+		t.Errorf("Expected b=1 as the only entry, got b=%d with %d entries", v, cache.Len())
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
 
-	cache.Set("c", 3)
+// Test a renamed value wins over a load of the new key in flight
+func TestRenameSupersedesLoad(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	computing, release := make(chan struct{}), make(chan struct{})
+	result := make(chan int)
+	go func() {
+		v, _ := cache.GetOrCompute("b", func() (int, error) {
+			close(computing)
+			<-release
+			return 2, nil
+		})
+		result <- v
+	}()
+	<-computing
 
-	stats := cache.Stats()
+	cache.Set("a", 1)
+	cache.Rename("a", "b")
+	close(release)
+	if v := <-result; v != 1 {
+		t.Errorf("Expected the waiting caller to get the renamed 1, got %d", v)
+	}
+	if v, _ := cache.Peek("b"); v != 1 {
+		t.Errorf("Expected the load not to overwrite the renamed b=1, got %d", v)
+	}
+}
 
-	if stats.Hits != 2 {
-		t.Errorf("Expected 2 hits, got %d", stats.Hits)
+// Test compact frequency-1 entries evict and promote like regular ones
+func TestCompactLowFrequency(t *testing.T) {
+	cache := newTestCache[int, int](100, time.Minute, nil, WithCompactLowFrequency[int, int](true))
+	for i := 0; i < 200; i++ {
+		cache.Set(i, i)
+		if i%10 == 0 {
+			cache.Get(i)
+		}
 	}
-	if stats.Misses != 1 {
-		t.Errorf("Expected 1 miss, got %d", stats.Misses)
+
+	if cache.Len() != 100 {
+		t.Errorf("Expected 100 entries, got %d", cache.Len())
 	}
-	if stats.Evictions != 1 {
-		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	for i := 0; i < 100; i += 10 {
+		if !cache.Contains(i) {
+			t.Errorf("Expected accessed key %d to survive the scan", i)
+		}
+	}
+	if cache.Contains(101) || !cache.Contains(199) {
+		t.Errorf("Expected one-hit keys to be evicted oldest first")
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
+
+// Test ReserveTransient keeps the hot set through a burst
+func TestReserveTransient(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("hot1", 1)
+	cache.Set("hot2", 2)
+	cache.Get("hot1")
+	cache.Get("hot2")
+
+	release := cache.ReserveTransient(3)
+	for _, k := range []string{"t1", "t2", "t3"} {
+		cache.Set(k, 0)
+	}
+	if cache.Len() != 5 || !cache.Contains("hot1") || !cache.Contains("hot2") {
+		t.Errorf("Expected the burst to fit beside the hot set, got %v", cache.Keys())
+	}
+
+	release()
+	release()
+	if cache.Len() != 2 || !cache.Contains("hot1") || !cache.Contains("hot2") {
+		t.Errorf("Expected only the hot set after release, got %v", cache.Keys())
+	}
+	cache.Set("next", 1)
+	if cache.Len() != 2 {
+		t.Errorf("Expected the original capacity of 2 to be restored, got %d entries", cache.Len())
+	}
+}
+
+// Test TrySet gives up when the lock stays held
+func TestTrySet(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+
+	cache.mu.Lock()
+	start := time.Now()
+	if cache.TrySet("a", 1, 20*time.Millisecond) {
+		t.Errorf("Expected TrySet to time out while the lock is held")
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Expected TrySet to wait for the timeout, returned after %v", d)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cache.mu.Unlock()
+	}()
+	if !cache.TrySet("b", 2, time.Second) {
+		t.Errorf("Expected TrySet to succeed once the lock is released")
+	}
+	if cache.Contains("a") || !cache.Contains("b") {
+		t.Errorf("Expected only b to be stored")
 	}
 }
 
@@ -178,3 +1372,425 @@ func BenchmarkLFU_Mixed(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkSerialGet reads from one goroutine with the keys built up
+// front, so the locking shows in the result.
+func benchmarkSerialGet(b *testing.B, opts ...Option[string, int]) {
+	cache := newTestCache(10000, time.Hour, nil, opts...)
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		cache.Set(keys[i], i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkLFU_SerialGet(b *testing.B) {
+	benchmarkSerialGet(b)
+}
+
+func BenchmarkLFU_SerialGetWithoutLocking(b *testing.B) {
+	benchmarkSerialGet(b, WithoutLocking[string, int]())
+}
+
+func benchmarkParallelGet(b *testing.B, opts ...Option[string, int]) {
+	cache := newTestCache(10000, time.Hour, nil, opts...)
+	for i := 0; i < 10000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(fmt.Sprintf("key-%d", i%10000))
+			i++
+		}
+	})
+}
+
+func BenchmarkLFU_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b)
+}
+
+func BenchmarkLFU_ParallelGetDeferred(b *testing.B) {
+	benchmarkParallelGet(b, WithDeferredIncrements[string, int](true))
+}
+
+func BenchmarkLFU_ParallelGetShardedCounters(b *testing.B) {
+	benchmarkParallelGet(b, WithShardedCounters[string, int](16))
+}
+
+// benchmarkCounter increments c from every core at once.
+func benchmarkCounter(b *testing.B, c *counter) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add(1)
+		}
+	})
+	if n := c.Load(); n != int64(b.N) {
+		b.Errorf("Expected a count of %d, got %d", b.N, n)
+	}
+}
+
+func BenchmarkCounter_Single(b *testing.B) {
+	benchmarkCounter(b, &counter{})
+}
+
+func BenchmarkCounter_Sharded(b *testing.B) {
+	c := &counter{}
+	c.shard(16)
+	benchmarkCounter(b, c)
+}
+
+// benchmarkHotSet hammers one key while a background scan keeps the lock
+// busy, as a cleanup pass on a large cache would.
+func benchmarkHotSet(b *testing.B, opts ...Option[string, int]) {
+	cache := newTestCache(10000, time.Hour, nil, opts...)
+	for i := 0; i < 10000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), i)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				cache.ExpiredCount()
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Set("hot", i)
+			i++
+		}
+	})
+	// Each applied update takes the lock once and bumps the frequency
+	b.StopTimer()
+	freq := 0
+	for _, kf := range cache.TopN(1) {
+		freq = kf.Frequency
+	}
+	b.ReportMetric(float64(freq)/float64(b.N), "locks/op")
+}
+
+func BenchmarkLFU_HotSet(b *testing.B) {
+	benchmarkHotSet(b)
+}
+
+func BenchmarkLFU_HotSetCoalesced(b *testing.B) {
+	benchmarkHotSet(b, WithSetCoalescing[string, int]())
+}
+
+func benchmarkFill(b *testing.B, opts ...Option[string, int]) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache := New(len(keys), time.Hour, 0, nil, opts...)
+		for j, key := range keys {
+			cache.Set(key, j)
+		}
+	}
+}
+
+// Maps are presized to the capacity by default
+func BenchmarkLFU_Fill(b *testing.B) {
+	benchmarkFill(b)
+}
+
+func BenchmarkLFU_FillUnsized(b *testing.B) {
+	benchmarkFill(b, WithInitialCapacityHint[string, int](1))
+}
+
+func benchmarkEvict(b *testing.B, opts ...Option[int, int]) {
+	const size = 1_000_000
+	cache := New(size, time.Hour, 0, nil, opts...)
+	for i := 0; i < size; i++ {
+		cache.Set(i, i)
+		if i%3 == 0 {
+			cache.Get(i)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(size+i, i) // every insert evicts
+	}
+}
+
+func BenchmarkLFU_EvictExact(b *testing.B) {
+	benchmarkEvict(b)
+}
+
+func BenchmarkLFU_EvictSampled(b *testing.B) {
+	benchmarkEvict(b, WithSampledEviction[int, int](5))
+}
+
+func BenchmarkLFU_MemoryPerEntry(b *testing.B) {
+	benchmarkMemoryPerEntry(b)
+}
+
+func BenchmarkLFU_MemoryPerEntryCompact(b *testing.B) {
+	benchmarkMemoryPerEntry(b, WithCompactLowFrequency[int, int](true))
+}
+
+// benchmarkMemoryPerEntry reports the heap bytes held per one-hit entry.
+func benchmarkMemoryPerEntry(b *testing.B, opts ...Option[int, int]) {
+	const size = 100000
+	var perEntry float64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		cache := New(size, time.Hour, 0, nil, opts...)
+		for j := 0; j < size; j++ {
+			cache.Set(j, j)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		perEntry = float64(after.HeapAlloc-before.HeapAlloc) / size
+		cache.Stop()
+	}
+	b.ReportMetric(perEntry, "B/entry")
+}
+
+// Test a scan of new keys doesn't push out the working set
+func TestAdmitOnlyIfColderVictim(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil, WithAdmitOnlyIfColderVictim[string, int](true))
+	hot := []string{"a", "b", "c", "d"}
+	for _, key := range hot {
+		cache.Set(key, 1)
+		cache.Get(key)
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprint("scan", i), i)
+	}
+	for _, key := range hot {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Expected hot key %s to survive the scan", key)
+		}
+	}
+	if stats := cache.Stats(); stats.Rejections != 100 {
+		t.Errorf("Expected 100 rejections, got %d", stats.Rejections)
+	}
+
+	cache.Set("a", 2)
+	if v, _ := cache.Get("a"); v != 2 {
+		t.Errorf("Expected updates to be applied, got %d", v)
+	}
+
+	// A victim used only once is no hotter than a new key
+	cache.Delete("d")
+	cache.Set("once", 1)
+	cache.Set("new", 1)
+	if _, ok := cache.Peek("new"); !ok {
+		t.Errorf("Expected new to be admitted over a colder victim")
+	}
+	if _, ok := cache.Peek("once"); ok {
+		t.Errorf("Expected once to be evicted")
+	}
+}
+
+// Test Get and cleanup both expire entries relative to their last access
+func TestSlidingTTL(t *testing.T) {
+	now := time.Now()
+	cache := New(4, 100*time.Millisecond, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithSlidingTTL[string, int](true))
+	defer cache.Stop()
+	start := now
+	cache.Set("used", 1)
+	cache.Set("idle", 2)
+
+	now = start.Add(80 * time.Millisecond)
+	cache.Get("used")
+	now = start.Add(150 * time.Millisecond)
+	cache.cleanupExpired()
+	if _, ok := cache.Peek("idle"); ok {
+		t.Errorf("Expected cleanup to reap the idle entry")
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected cleanup to keep the recently used entry, got %d entries", n)
+	}
+	if _, ok := cache.Get("used"); !ok {
+		t.Errorf("Expected Get to serve the recently used entry")
+	}
+
+	now = start.Add(240 * time.Millisecond)
+	cache.cleanupExpired()
+	if _, ok := cache.Get("used"); !ok {
+		t.Errorf("Expected the last Get to extend the TTL")
+	}
+	now = start.Add(400 * time.Millisecond)
+	cache.cleanupExpired()
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected cleanup to reap the entry once unused for the TTL, got %d entries", n)
+	}
+}
+
+// Test callers mutating returned values don't change the cached copy
+func TestValueCopier(t *testing.T) {
+	cache := newTestCache[string, []int](4, time.Minute, nil,
+		WithValueCopier[string, []int](func(v []int) []int { return append([]int(nil), v...) }))
+	cache.Set("a", []int{1, 2, 3})
+
+	got, _ := cache.Get("a")
+	got[0] = 100
+	peeked, _ := cache.Peek("a")
+	peeked[1] = 200
+	cache.Range(func(_ string, v []int) bool {
+		v[2] = 300
+		return true
+	})
+
+	if v, _ := cache.Get("a"); fmt.Sprint(v) != "[1 2 3]" {
+		t.Errorf("Expected the cached copy to stay [1 2 3], got %v", v)
+	}
+}
+
+// Test expired keys are sent on the expiration channel
+func TestExpirationChannel(t *testing.T) {
+	now := time.Now()
+	ch := make(chan string, 4)
+	cache := New(2, time.Minute, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithExpirationChannel[string, int](ch))
+	defer cache.Stop()
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Delete("b")
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Set("c", 3) // evicts a
+	now = now.Add(2 * time.Minute)
+
+	cache.Get("b") // expires b lazily
+	cache.DrainExpired(0)
+	var got []string
+	for len(ch) > 0 {
+		got = append(got, <-ch)
+	}
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("Expected only the expired b then c, got %v", got)
+	}
+	if d := cache.DroppedExpirations(); d != 0 {
+		t.Errorf("Expected no drops, got %d", d)
+	}
+}
+
+// Test expired keys are dropped and counted when the channel is full
+func TestExpirationChannelFull(t *testing.T) {
+	ch := make(chan string, 1)
+	cache := newTestCache[string, int](4, 10*time.Millisecond, nil, WithExpirationChannel[string, int](ch))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	time.Sleep(20 * time.Millisecond)
+	cache.DrainExpired(0)
+
+	if len(ch) != 1 || cache.DroppedExpirations() != 1 {
+		t.Errorf("Expected one key sent and one dropped, got %d sent and %d dropped", len(ch), cache.DroppedExpirations())
+	}
+}
+
+// Test GetAndRefresh extends the lifetime of live keys only
+func TestGetAndRefresh(t *testing.T) {
+	now := time.Now()
+	cache := New(4, time.Minute, 0, nil, WithClock[string, int](func() time.Time { return now }))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	now = now.Add(50 * time.Second)
+	if v, ok := cache.GetAndRefresh("a", 5*time.Minute); !ok || v != 1 {
+		t.Errorf("Expected a=1, got %v, %v", v, ok)
+	}
+	if v, ok := cache.GetAndRefresh("b", 0); !ok || v != 2 {
+		t.Errorf("Expected b=2, got %v, %v", v, ok)
+	}
+	if f := frequencyOf(cache, "a"); f != 2 {
+		t.Errorf("Expected the read to bump a to frequency 2, got %d", f)
+	}
+
+	now = now.Add(55 * time.Second)
+	if !cache.Contains("a") || !cache.Contains("b") {
+		t.Errorf("Expected a and b to outlive their original TTL")
+	}
+	now = now.Add(10 * time.Second)
+	if !cache.Contains("a") || cache.Contains("b") {
+		t.Errorf("Expected b to expire a default TTL after its refresh, and a to live on")
+	}
+	now = now.Add(4 * time.Minute)
+	if cache.Contains("a") {
+		t.Errorf("Expected a to expire 5 minutes after its refresh")
+	}
+
+	if _, ok := cache.GetAndRefresh("a", time.Minute); ok {
+		t.Errorf("Expected expired a to miss")
+	}
+	if _, ok := cache.GetAndRefresh("missing", time.Minute); ok {
+		t.Errorf("Expected a missing key to miss")
+	}
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 2 {
+		t.Errorf("Expected 2 hits and 2 misses, got %+v", s)
+	}
+}
+
+// Test a cache without locking works and starts no cleanup loop
+func TestWithoutLocking(t *testing.T) {
+	cache := newTestCache(2, 10*time.Millisecond, nil, WithoutLocking[string, int]())
+	if cache.CleanupRunning() {
+		t.Errorf("Expected no cleanup loop without locking")
+	}
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Set("b", 2)
+	cache.Set("c", 3) // evicts b
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := cache.ExpiredCount(); n != 2 {
+		t.Errorf("Expected expired entries to wait for a read or DrainExpired, got %d", n)
+	}
+	if n := cache.DrainExpired(0); n != 2 || cache.Len() != 0 {
+		t.Errorf("Expected DrainExpired to reap both entries, got %d", n)
+	}
+}
+
+// Test reservations don't race with ReplaceAll and the utilization alert
+func TestReserveTransientConcurrent(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil,
+		WithUtilizationAlert[string, int](0.5, func(float64) {}))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				switch i {
+				case 0:
+					cache.ReserveTransient(2)()
+				case 1:
+					cache.ReplaceAll(map[string]int{"a": 1, "b": 2})
+				default:
+					cache.Set(fmt.Sprint(n), n)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := cache.checkInvariants(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+package lfu
+
+import (
+	"io"
+	"time"
+)
+
+// EvictionReason tells an eviction listener why an entry left the cache.
+type EvictionReason int
+
+const (
+	ReasonCapacity EvictionReason = iota // evicted to make room
+	ReasonExpired                        // outlived its TTL
+	ReasonReplaced                       // dropped by ReplaceAll
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// eviction is a callback invocation queued while c.mu is held.
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+	age    time.Duration
+	freq   int
+}
+
+// hasEvictionCallbacks reports whether anyone listens for evictions.
+func (c *LFUCache[K, V]) hasEvictionCallbacks() bool {
+	return c.onEvict != nil || c.evictListener != nil || c.observeEvictAge != nil || c.logger != nil
+}
+
+// queueEvicted schedules the eviction callbacks for ent. Must be called
+// with c.mu held.
+func (c *LFUCache[K, V]) queueEvicted(ent *entry[K, V], reason EvictionReason) {
+	// With a victim cache, capacity victims are closed when they leave it
+	if reason != ReasonCapacity || c.victims == nil {
+		c.queueClose(ent.value)
+	}
+	if c.hasEvictionCallbacks() {
+		c.evicted = append(c.evicted, eviction[K, V]{ent.key, ent.value, reason, c.clock().Sub(ent.createdAt), ent.frequency})
+	}
+}
+
+// log passes msg and its key-value pairs to the logger, if any.
+func (c *LFUCache[K, V]) log(msg string, kv ...any) {
+	if c.logger != nil {
+		c.logger(msg, kv...)
+	}
+}
+
+// queueClose schedules value to be closed if auto-close is enabled and it
+// is an io.Closer. Must be called with c.mu held.
+func (c *LFUCache[K, V]) queueClose(value V) {
+	if !c.autoClose {
+		return
+	}
+	if closer, ok := any(value).(io.Closer); ok {
+		c.closing = append(c.closing, closer)
+	}
+}
+
+// dispatch hands ev to the callback workers, or runs the callbacks inline
+// when there are none.
+func (c *LFUCache[K, V]) dispatch(ev eviction[K, V]) {
+	c.callbackMu.RLock()
+	if c.callbackQueue != nil {
+		c.callbackQueue <- ev
+		c.callbackMu.RUnlock()
+		return
+	}
+	c.callbackMu.RUnlock()
+	c.runCallbacks(ev)
+}
+
+func (c *LFUCache[K, V]) runCallbacks(ev eviction[K, V]) {
+	if c.onEvict != nil {
+		c.onEvict(ev.key, ev.value)
+	}
+	if c.evictListener != nil {
+		c.evictListener(ev.key, ev.value, ev.reason)
+	}
+	if c.observeEvictAge != nil && ev.reason == ReasonCapacity {
+		c.observeEvictAge(ev.age)
+	}
+	if c.logger != nil {
+		c.logger("entry evicted", "reason", ev.reason.String(), "key", ev.key, "frequency", ev.freq, "age", ev.age)
+	}
+}
+
+func (c *LFUCache[K, V]) startCallbackWorkers() {
+	c.callbackQueue = make(chan eviction[K, V], c.callbackWorkers)
+	c.callbackDone = make(chan struct{})
+	c.callbackLive.Store(int32(c.callbackWorkers))
+	for i := 0; i < c.callbackWorkers; i++ {
+		go func(queue <-chan eviction[K, V]) {
+			defer func() {
+				if c.callbackLive.Add(-1) == 0 {
+					close(c.callbackDone)
+				}
+			}()
+			for ev := range queue {
+				c.runCallbacks(ev)
+			}
+		}(c.callbackQueue)
+	}
+}
+
+// stopCallbackWorkers waits for queued callbacks to run and shuts the
+// workers down. Later callbacks run inline.
+func (c *LFUCache[K, V]) stopCallbackWorkers() {
+	c.callbackMu.Lock()
+	if c.callbackQueue != nil {
+		close(c.callbackQueue)
+		c.callbackQueue = nil
+	}
+	c.callbackMu.Unlock()
+	if c.callbackDone != nil {
+		<-c.callbackDone
+	}
+}
@@ -0,0 +1,168 @@
+package lfu
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test eviction callbacks run after the lock is released
+func TestCallbackOutsideLock(t *testing.T) {
+	var cache *LFUCache[string, int]
+	cache = newTestCache(1, time.Minute, func(k string, v int) {
+		// Re-entering the cache would deadlock if the lock were still held
+		cache.Len()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected callback to run without holding the lock")
+	}
+}
+
+// Test async callbacks run concurrently and Stop waits for them
+func TestAsyncCallbacks(t *testing.T) {
+	var running, peak, calls atomic.Int32
+	var mu sync.Mutex
+	cache := newTestCache(1, time.Minute, func(k int, v int) {
+		n := running.Add(1)
+		mu.Lock()
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		running.Add(-1)
+		calls.Add(1)
+	}, WithAsyncCallbacks[int, int](4))
+
+	start := time.Now()
+	for i := 0; i < 9; i++ {
+		cache.Set(i, i) // every Set after the first evicts
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected Sets not to wait for callbacks, took %v", elapsed)
+	}
+
+	cache.Stop()
+	if n := calls.Load(); n != 8 {
+		t.Errorf("Expected Stop to wait for 8 callbacks, got %d", n)
+	}
+	if p := peak.Load(); p < 2 || p > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent callbacks, got %d", p)
+	}
+
+	cache.Set(100, 100) // callbacks run inline after Stop
+	if n := calls.Load(); n != 9 {
+		t.Errorf("Expected inline callback after Stop, got %d calls", n)
+	}
+}
+
+// Test the eviction age observer reports the victim's age
+func TestEvictionAgeObserver(t *testing.T) {
+	now := time.Now()
+	var ages []time.Duration
+	cache := New(1, time.Hour, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithEvictionAgeObserver[string, int](func(age time.Duration) {
+			ages = append(ages, age)
+		}))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	now = now.Add(3 * time.Second)
+	cache.Set("b", 2)
+	cache.Delete("b")
+
+	if len(ages) != 1 || ages[0] != 3*time.Second {
+		t.Errorf("Expected a single age of 3s, got %v", ages)
+	}
+}
+
+// resource is an io.Closer that records whether it was closed.
+type resource struct{ closed bool }
+
+func (r *resource) Close() error {
+	r.closed = true
+	return nil
+}
+
+// Test WithAutoClose closes evicted and deleted values
+func TestAutoClose(t *testing.T) {
+	cache := newTestCache[string, *resource](1, time.Minute, nil, WithAutoClose[string, *resource](true))
+	a, b, c := &resource{}, &resource{}, &resource{}
+
+	cache.Set("a", a)
+	cache.Set("b", b) // evicts a
+	if !a.closed {
+		t.Errorf("Expected the evicted value to be closed")
+	}
+	cache.Delete("b")
+	if !b.closed {
+		t.Errorf("Expected the deleted value to be closed")
+	}
+	cache.Set("c", c)
+	if v, _ := cache.Take("c"); v.closed {
+		t.Errorf("Expected a taken value to stay open")
+	}
+}
+
+// Test WithLogger reports a forced eviction with its details
+func TestLogger(t *testing.T) {
+	var logs []string
+	cache := newTestCache[string, int](1, time.Minute, nil,
+		WithLogger[string, int](func(msg string, kv ...any) {
+			logs = append(logs, fmt.Sprintln(append([]any{msg}, kv...)...))
+		}))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Set("b", 2)
+
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log line, got %v", logs)
+	}
+	for _, want := range []string{"entry evicted", "reason capacity", "key a", "frequency 2"} {
+		if !strings.Contains(logs[0], want) {
+			t.Errorf("Expected log line to contain %q, got %q", want, logs[0])
+		}
+	}
+}
+
+// Test the emptiness callbacks fire once per transition
+func TestOnEmptyOnNonEmpty(t *testing.T) {
+	var empty, nonEmpty int
+	cache := newTestCache[string, int](4, time.Minute, nil,
+		WithOnEmpty[string, int](func() { empty++ }),
+		WithOnNonEmpty[string, int](func() { nonEmpty++ }))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("a", 3)
+	if empty != 0 || nonEmpty != 1 {
+		t.Errorf("Expected (0, 1) transitions after filling, got (%d, %d)", empty, nonEmpty)
+	}
+
+	cache.Delete("a")
+	cache.Delete("missing")
+	cache.Delete("b")
+	cache.Delete("b")
+	if empty != 1 || nonEmpty != 1 {
+		t.Errorf("Expected (1, 1) transitions after emptying, got (%d, %d)", empty, nonEmpty)
+	}
+
+	cache.ReplaceAll(map[string]int{"c": 1, "d": 2})
+	cache.Set("e", 3)
+	if empty != 1 || nonEmpty != 2 {
+		t.Errorf("Expected (1, 2) transitions after refilling, got (%d, %d)", empty, nonEmpty)
+	}
+}
@@ -0,0 +1,171 @@
+package lfu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Codec serializes individual cache entries for Save and Load.
+// Each call to Encode writes exactly one entry and each call to Decode
+// reads one back from a reader holding only that entry.
+type Codec[K comparable, V any] interface {
+	Encode(w io.Writer, key K, value V) error
+	Decode(r io.Reader) (K, V, error)
+}
+
+// GobCodec encodes entries with encoding/gob. It is the default codec.
+type GobCodec[K comparable, V any] struct{}
+
+func (GobCodec[K, V]) Encode(w io.Writer, key K, value V) error {
+	return gob.NewEncoder(w).Encode(KeyValue[K, V]{Key: key, Value: value})
+}
+
+func (GobCodec[K, V]) Decode(r io.Reader) (K, V, error) {
+	var rec KeyValue[K, V]
+	err := gob.NewDecoder(r).Decode(&rec)
+	return rec.Key, rec.Value, err
+}
+
+// JSONCodec encodes entries with encoding/json.
+type JSONCodec[K comparable, V any] struct{}
+
+func (JSONCodec[K, V]) Encode(w io.Writer, key K, value V) error {
+	return json.NewEncoder(w).Encode(KeyValue[K, V]{Key: key, Value: value})
+}
+
+func (JSONCodec[K, V]) Decode(r io.Reader) (K, V, error) {
+	var rec KeyValue[K, V]
+	err := json.NewDecoder(r).Decode(&rec)
+	return rec.Key, rec.Value, err
+}
+
+// Save writes all live entries to w using the configured codec.
+// Entries are written coldest first, so loading into a smaller cache keeps
+// the hottest ones. Frequencies and timestamps are not persisted.
+func (c *LFUCache[K, V]) Save(w io.Writer) error {
+	items := c.snapshot()
+
+	var frame bytes.Buffer
+	var size [binary.MaxVarintLen64]byte
+	for _, it := range items {
+		frame.Reset()
+		if err := c.codec.Encode(&frame, it.Key, it.Value); err != nil {
+			return err
+		}
+		// Length-prefix each entry so codecs that buffer reads stay in bounds
+		n := binary.PutUvarint(size[:], uint64(frame.Len()))
+		if _, err := w.Write(size[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportBatch is how many entries StreamExport visits per lock hold.
+const exportBatch = 1024
+
+// StreamExport calls emit for each live entry, stopping at the first error
+// emit returns or once ctx is done, whose error it then returns. Unlike
+// Save it never holds the read lock for more than a batch of entries: it
+// releases the lock after each batch, calls emit for the batch outside
+// it, and then resumes where it left off. The export is therefore not a
+// point-in-time snapshot: entries inserted while it runs may or may not
+// be included, entries removed before being reached are skipped, and
+// entries updated before being reached are exported with their newest
+// value. A key deleted and set again while the export runs may be
+// exported twice, so consumers should let a later value win.
+func (c *LFUCache[K, V]) StreamExport(ctx context.Context, emit func(K, V) error) error {
+	flush := func(batch []KeyValue[K, V]) error {
+		for _, kv := range batch {
+			if err := emit(kv.Key, c.copyOut(kv.Value)); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	batch := make([]KeyValue[K, V], 0, exportBatch)
+	visited := 0
+	c.rlock()
+	now := c.clock()
+	// Go allows a range over a map to go on while the map is modified, so
+	// the loop itself is the cursor. Lookups go through c.keyMap in case
+	// the map was replaced meanwhile, by ShrinkToFit for example.
+	keys := c.keyMap
+	for key := range keys {
+		if ent, ok := c.keyMap[key]; ok && c.overdue(ent, now) <= 0 {
+			batch = append(batch, KeyValue[K, V]{Key: key, Value: ent.value})
+		}
+		if visited++; visited < exportBatch {
+			continue
+		}
+		c.mu.RUnlock()
+		if err := flush(batch); err != nil {
+			return err
+		}
+		batch, visited = batch[:0], 0
+		c.rlock()
+		now = c.clock()
+	}
+	c.mu.RUnlock()
+	return flush(batch)
+}
+
+// Load reads entries written by Save and inserts them with Set.
+func (c *LFUCache[K, V]) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		n, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(br, frame); err != nil {
+			return err
+		}
+		key, value, err := c.codec.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return err
+		}
+		c.Set(key, value)
+	}
+}
+
+// snapshot copies the live entries in ascending eviction priority.
+func (c *LFUCache[K, V]) snapshot() []KeyValue[K, V] {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	items := make([]KeyValue[K, V], 0, c.size)
+	for _, freq := range freqs {
+		c.freqMap[freq].eachOldest(func(ent *entry[K, V]) bool {
+			if !c.isExpired(ent) {
+				items = append(items, KeyValue[K, V]{Key: ent.key, Value: ent.value})
+			}
+			return true
+		})
+	}
+	return items
+}
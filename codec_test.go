@@ -0,0 +1,166 @@
+package lfu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test a save/load round trip through each bundled codec
+func TestSaveLoad(t *testing.T) {
+	codecs := map[string]Codec[string, int]{
+		"gob":  GobCodec[string, int]{},
+		"json": JSONCodec[string, int]{},
+	}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			src := newTestCache[string, int](3, time.Minute, nil, WithCodec(codec))
+			src.Set("a", 1)
+			src.Set("b", 2)
+			src.Set("c", 3)
+
+			var buf bytes.Buffer
+			if err := src.Save(&buf); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			dst := newTestCache[string, int](3, time.Minute, nil, WithCodec(codec))
+			if err := dst.Load(&buf); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if dst.Len() != 3 {
+				t.Errorf("Expected length 3, got %d", dst.Len())
+			}
+			for k, want := range map[string]int{"a": 1, "b": 2, "c": 3} {
+				if v, ok := dst.Get(k); !ok || v != want {
+					t.Errorf("Expected %s=%d, got %v", k, want, v)
+				}
+			}
+		})
+	}
+}
+
+// Test loading into a smaller cache keeps the hottest entries
+func TestLoadKeepsHotEntries(t *testing.T) {
+	src := newTestCache[string, int](3, time.Minute, nil)
+	src.Set("a", 1)
+	src.Set("b", 2)
+	src.Set("c", 3)
+	src.Get("a")
+	src.Get("a")
+	src.Get("b")
+
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	dst := newTestCache[string, int](2, time.Minute, nil)
+	if err := dst.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := dst.Get("c"); ok {
+		t.Errorf("Expected cold key c to be dropped")
+	}
+	if _, ok := dst.Get("a"); !ok {
+		t.Errorf("Expected hot key a to be loaded")
+	}
+}
+
+// Test StreamExport exports a large cache while writes go on
+func TestStreamExport(t *testing.T) {
+	const n = 10 * exportBatch
+	cache := newTestCache[int, int](2*n, time.Minute, nil)
+	for i := 0; i < n; i++ {
+		cache.Set(i, i)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := n; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				cache.Set(i, i)
+				if i > n {
+					cache.Delete(i - 1)
+				}
+			}
+		}
+	}()
+
+	exported := 0
+	err := cache.StreamExport(context.Background(), func(k, v int) error {
+		if k != v {
+			t.Errorf("Expected %d=%d, got %d", k, k, v)
+		}
+		if k < n {
+			exported++
+		}
+		return nil
+	})
+	close(stop)
+	<-done
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if exported != n {
+		t.Errorf("Expected each of the %d untouched entries once, got %d", n, exported)
+	}
+}
+
+// Test StreamExport releases the lock between batches
+func TestStreamExportReleasesLock(t *testing.T) {
+	cache := newTestCache[int, int](4*exportBatch, time.Minute, nil)
+	for i := 0; i < 2*exportBatch; i++ {
+		cache.Set(i, i)
+	}
+	calls := 0
+	cache.StreamExport(context.Background(), func(k, v int) error {
+		if calls++; calls == exportBatch {
+			if !cache.mu.TryLock() {
+				t.Errorf("Expected the lock to be free while a batch is emitted")
+			} else {
+				cache.mu.Unlock()
+			}
+		}
+		return nil
+	})
+	if calls != 2*exportBatch {
+		t.Errorf("Expected %d entries, got %d", 2*exportBatch, calls)
+	}
+}
+
+// Test StreamExport stops on cancellation and emit errors
+func TestStreamExportStops(t *testing.T) {
+	cache := newTestCache[int, int](3*exportBatch, time.Minute, nil)
+	for i := 0; i < 3*exportBatch; i++ {
+		cache.Set(i, i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := cache.StreamExport(ctx, func(k, v int) error {
+		calls++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != exportBatch {
+		t.Errorf("Expected to stop after the first batch, got %v after %d calls", err, calls)
+	}
+
+	failed := errors.New("write failed")
+	calls = 0
+	err = cache.StreamExport(context.Background(), func(k, v int) error {
+		calls++
+		return failed
+	})
+	if !errors.Is(err, failed) || calls != 1 {
+		t.Errorf("Expected the emit error after one call, got %v after %d calls", err, calls)
+	}
+}
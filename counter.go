@@ -0,0 +1,45 @@
+package lfu
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync/atomic"
+)
+
+// counter is an atomic counter that can be split into shards, so that
+// concurrent increments mostly land on different cache lines.
+type counter struct {
+	single atomic.Int64
+	shards []paddedCounter // nil unless sharded
+}
+
+// paddedCounter takes up a whole cache line.
+type paddedCounter struct {
+	n atomic.Int64
+	_ [56]byte
+}
+
+// shard splits the counter into at least n shards, rounded up to a power
+// of two. It must be called before the counter is used.
+func (c *counter) shard(n int) {
+	if n <= 1 {
+		return
+	}
+	c.shards = make([]paddedCounter, 1<<bits.Len(uint(n-1)))
+}
+
+func (c *counter) Add(delta int64) {
+	if c.shards == nil {
+		c.single.Add(delta)
+		return
+	}
+	c.shards[rand.Uint32()&uint32(len(c.shards)-1)].n.Add(delta)
+}
+
+func (c *counter) Load() int64 {
+	n := c.single.Load()
+	for i := range c.shards {
+		n += c.shards[i].n.Load()
+	}
+	return n
+}
@@ -0,0 +1,231 @@
+package lfu
+
+import (
+	"math"
+	"sort"
+)
+
+// SetWithDecayWeight inserts or updates key and sets how strongly its
+// frequency decays: 1 is the default rate, values below 1 decay slower and
+// 0 disables decay for the entry. Only meaningful with WithFrequencyDecay.
+// Errors are dropped like with Set.
+func (c *LFUCache[K, V]) SetWithDecayWeight(key K, value V, decayWeight float64) {
+	if decayWeight < 0 {
+		decayWeight = 0
+	}
+	_ = c.writeLocked(key, value, func() {
+		c.set(key, value)
+		if ent, ok := c.keyMap[key]; ok {
+			ent.decayWeight = decayWeight
+		}
+	})
+}
+
+// decayFrequencies reduces every entry's frequency by decayFactor scaled
+// by the entry's weight, never dropping below 1, then renumbers the
+// frequencies to 1..k.
+func (c *LFUCache[K, V]) decayFrequencies() {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	c.drainPending()
+	c.rebuildBuckets(func(ent *entry[K, V]) int {
+		freq := ent.frequency - int(float64(ent.frequency)*c.decayFactor*ent.decayWeight)
+		if freq < 1 {
+			freq = 1
+		}
+		return freq
+	})
+	// Keep the buckets dense so they can't fragment over many passes
+	c.normalize()
+}
+
+// rotateWindow ages out the oldest time bucket of WithWindow and sets
+// each entry's frequency to its accesses in the remaining ones, but at
+// least 1.
+func (c *LFUCache[K, V]) rotateWindow() {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	c.drainPending()
+	next := (c.windowSlot + 1) % c.windowBuckets
+	c.rebuildBuckets(func(ent *entry[K, V]) int {
+		window := c.windowOf(ent)
+		window[next] = 0
+		freq := 0
+		for _, n := range window {
+			freq += n
+		}
+		return max(freq, 1)
+	})
+	c.windowSlot = next
+}
+
+// windowOf returns the time buckets of ent, starting them with its current
+// frequency in the current bucket. Must be called with c.mu held.
+func (c *LFUCache[K, V]) windowOf(ent *entry[K, V]) []int {
+	if ent.window == nil {
+		ent.window = make([]int, c.windowBuckets)
+		ent.window[c.windowSlot] = ent.frequency
+	}
+	return ent.window
+}
+
+// NormalizeFrequencies renumbers the distinct frequencies to 1..k in
+// order, collapsing sparse high buckets while keeping eviction priority.
+func (c *LFUCache[K, V]) NormalizeFrequencies() {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	c.drainPending()
+	c.normalize()
+}
+
+// ScaleFrequencies multiplies every frequency by factor, rounding to the
+// nearest integer but at least 1. A factor below 1 decays frequencies in
+// one shot, one above 1 inflates them. Entries keep their relative order,
+// though entries whose frequencies round to the same value share a bucket.
+func (c *LFUCache[K, V]) ScaleFrequencies(factor float64) {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	c.drainPending()
+	c.rebuildBuckets(func(ent *entry[K, V]) int {
+		freq := math.Round(float64(ent.frequency) * factor)
+		if !(freq >= 1) { // also catches NaN
+			return 1
+		}
+		return int(min(freq, math.MaxInt32))
+	})
+}
+
+// normalize renumbers the distinct frequencies to 1..k in order.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) normalize() {
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+	rank := make(map[int]int, len(freqs))
+	for i, freq := range freqs {
+		rank[freq] = i + 1
+	}
+	c.rebuildBuckets(func(ent *entry[K, V]) int {
+		return rank[ent.frequency]
+	})
+}
+
+// ExportPriority returns the frequency of every key in eviction order,
+// the next victim first, without the values.
+func (c *LFUCache[K, V]) ExportPriority() []KeyFreq[K] {
+	c.lock()
+	defer c.unlock()
+	c.drainPending()
+
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+	priority := make([]KeyFreq[K], 0, c.size)
+	for _, freq := range freqs {
+		c.freqMap[freq].eachOldest(func(ent *entry[K, V]) bool {
+			priority = append(priority, KeyFreq[K]{Key: ent.key, Frequency: freq})
+			return true
+		})
+	}
+	return priority
+}
+
+// ImportPriority applies the frequencies in p, as returned by
+// ExportPriority, to the keys still in the cache. Imported keys sharing a
+// frequency are evicted in the order they appear in p, after any keys p
+// doesn't mention. Keys no longer cached and frequencies below 1 are ignored.
+func (c *LFUCache[K, V]) ImportPriority(p []KeyFreq[K]) {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	c.drainPending()
+
+	freqs := make(map[K]int, len(p))
+	for _, kf := range p {
+		if kf.Frequency >= 1 {
+			freqs[kf.Key] = kf.Frequency
+		}
+	}
+	c.rebuildBuckets(func(ent *entry[K, V]) int {
+		if freq, ok := freqs[ent.key]; ok {
+			return freq
+		}
+		return ent.frequency
+	})
+	for _, kf := range p {
+		if ent, ok := c.keyMap[kf.Key]; ok && kf.Frequency >= 1 {
+			c.freqMap[ent.frequency].moveToFront(ent)
+		}
+	}
+}
+
+// rebuildBuckets assigns each entry the frequency returned by newFreq and
+// regroups entries into buckets. Entries keep their relative recency, with
+// those from lower old buckets placed behind those from higher ones.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) rebuildBuckets(newFreq func(*entry[K, V]) int) {
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	old := c.freqMap
+	c.freqMap = make(map[int]*freqList[K, V], len(old))
+	for _, freq := range freqs {
+		old[freq].eachOldest(func(ent *entry[K, V]) bool {
+			ent.frequency = newFreq(ent)
+			if c.freqMap[ent.frequency] == nil {
+				c.freqMap[ent.frequency] = c.newBucket(ent.frequency)
+			}
+			c.freqMap[ent.frequency].pushFront(ent)
+			return true
+		})
+	}
+	c.resetMinFreq()
+}
+
+// limitBuckets merges the two closest frequency buckets until at most
+// maxBuckets remain. The lower bucket's entries take the higher frequency
+// and queue up behind the higher bucket's entries, so they are still
+// evicted first. Must be called with c.mu held.
+func (c *LFUCache[K, V]) limitBuckets() {
+	for len(c.freqMap) > c.maxBuckets {
+		freqs := make([]int, 0, len(c.freqMap))
+		for freq := range c.freqMap {
+			freqs = append(freqs, freq)
+		}
+		sort.Ints(freqs)
+		closest := 0
+		for i := 1; i < len(freqs)-1; i++ {
+			if freqs[i+1]-freqs[i] < freqs[closest+1]-freqs[closest] {
+				closest = i
+			}
+		}
+
+		lo, hi := freqs[closest], freqs[closest+1]
+		from, to := c.freqMap[lo], c.freqMap[hi]
+		from.eachNewest(func(ent *entry[K, V]) bool {
+			ent.frequency = hi
+			to.pushBack(ent)
+			return true
+		})
+		delete(c.freqMap, lo)
+		if c.minFreq == lo {
+			c.minFreq = hi
+		}
+	}
+}
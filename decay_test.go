@@ -0,0 +1,384 @@
+package lfu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func frequencyOf[K comparable, V any](c *LFUCache[K, V], key K) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ent, ok := c.keyMap[key]; ok {
+		return ent.frequency
+	}
+	return 0
+}
+
+// Test decay honors per-entry weights
+func TestDecayWeight(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil,
+		WithFrequencyDecay[string, int](time.Hour, 0.5))
+
+	cache.Set("a", 1)
+	cache.SetWithDecayWeight("b", 2, 0)
+	cache.SetWithDecayWeight("c", 3, 0.5)
+	for i := 0; i < 7; i++ {
+		cache.Get("a")
+		cache.Get("b")
+		cache.Get("c")
+	}
+
+	cache.decayFrequencies()
+
+	// a decays to 4, b stays at 8 and c decays to 6, renumbered in order
+	if f := frequencyOf(cache, "a"); f != 1 {
+		t.Errorf("Expected a to decay the most, to rank 1, got %d", f)
+	}
+	if f := frequencyOf(cache, "b"); f != 3 {
+		t.Errorf("Expected b not to decay, staying at rank 3, got %d", f)
+	}
+	if f := frequencyOf(cache, "c"); f != 2 {
+		t.Errorf("Expected c to decay half as much, to rank 2, got %d", f)
+	}
+}
+
+// Test the background loop decays frequencies so stale keys become evictable
+func TestFrequencyDecayLoop(t *testing.T) {
+	cache := New[string, int](2, time.Minute, 0, nil,
+		WithFrequencyDecay[string, int](20*time.Millisecond, 0.9))
+	defer cache.Stop()
+
+	cache.Set("old", 1)
+	for i := 0; i < 20; i++ {
+		cache.Get("old")
+	}
+	time.Sleep(70 * time.Millisecond)
+
+	if f := frequencyOf(cache, "old"); f != 1 {
+		t.Errorf("Expected frequency to decay to 1, got %d", f)
+	}
+}
+
+// Test normalization compacts frequencies while keeping their order
+func TestNormalizeFrequencies(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	for i := 0; i < 4; i++ {
+		cache.Get("b")
+	}
+	for i := 0; i < 19; i++ {
+		cache.Get("c")
+	}
+
+	cache.NormalizeFrequencies()
+
+	for key, want := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		if f := frequencyOf(cache, key); f != want {
+			t.Errorf("Expected %s at frequency %d, got %d", key, want, f)
+		}
+	}
+	cache.Set("d", 4)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to remain the eviction victim")
+	}
+}
+
+// Test ScaleFrequencies scales frequencies while keeping their order
+func TestScaleFrequencies(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	for i := 0; i < 9; i++ {
+		cache.Get("b")
+	}
+	for i := 0; i < 19; i++ {
+		cache.Get("c")
+	}
+
+	cache.ScaleFrequencies(0.5)
+	for key, want := range map[string]int{"a": 1, "b": 5, "c": 10} {
+		if f := frequencyOf(cache, key); f != want {
+			t.Errorf("Expected %s at frequency %d, got %d", key, want, f)
+		}
+	}
+	cache.ScaleFrequencies(3)
+	for key, want := range map[string]int{"a": 3, "b": 15, "c": 30} {
+		if f := frequencyOf(cache, key); f != want {
+			t.Errorf("Expected %s at frequency %d, got %d", key, want, f)
+		}
+	}
+	cache.Set("d", 4)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to remain the eviction victim")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("Expected b to be kept over a")
+	}
+}
+
+// Test WithFrequencyCeiling demotes keys instead of letting them climb
+func TestFrequencyCeiling(t *testing.T) {
+	cache := newTestCache(2, time.Minute, nil, WithFrequencyCeiling[string, int](4, 2))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	for i := 0; i < 3; i++ {
+		cache.Get("a")
+	}
+	if f := frequencyOf(cache, "a"); f != 4 {
+		t.Errorf("Expected a to reach the ceiling, got %d", f)
+	}
+	cache.Get("a")
+	if f := frequencyOf(cache, "a"); f != 2 {
+		t.Errorf("Expected a to be demoted to 2, got %d", f)
+	}
+	cache.Get("a")
+	if f := frequencyOf(cache, "a"); f != 3 {
+		t.Errorf("Expected a to climb again from 2, got %d", f)
+	}
+
+	cache.Get("b")
+	cache.Get("b")
+	cache.Get("b")
+	cache.Get("b") // demotes b to 2, below a
+	cache.Set("c", 3)
+	if cache.Contains("b") || !cache.Contains("a") {
+		t.Errorf("Expected the demoted b to be evicted")
+	}
+}
+
+// Test WithMaxBuckets merges buckets to stay under the limit
+func TestMaxBuckets(t *testing.T) {
+	cache := newTestCache[int, int](100, time.Minute, nil, WithMaxBuckets[int, int](4))
+	for i := 0; i < 20; i++ {
+		cache.Set(i, i)
+		for j := 0; j < i; j++ {
+			cache.Get(i)
+		}
+	}
+
+	cache.mu.RLock()
+	buckets := len(cache.freqMap)
+	for freq, list := range cache.freqMap {
+		list.eachNewest(func(ent *entry[int, int]) bool {
+			if ent.frequency != freq {
+				t.Errorf("Expected entry in bucket %d to have frequency %d, got %d", freq, freq, ent.frequency)
+			}
+			return true
+		})
+	}
+	if cache.freqMap[cache.minFreq] == nil {
+		t.Errorf("Expected minFreq %d to name a bucket", cache.minFreq)
+	}
+	cache.mu.RUnlock()
+
+	if buckets > 4 {
+		t.Errorf("Expected at most 4 buckets, got %d", buckets)
+	}
+	if top := cache.TopN(1); len(top) != 1 || top[0].Key != 19 {
+		t.Errorf("Expected 19 to stay the hottest key, got %v", top)
+	}
+	if cache.Len() != 20 {
+		t.Errorf("Expected all 20 entries kept, got %d", cache.Len())
+	}
+}
+
+// Test WithFrequencyDebounce counts repeated reads once per window
+func TestFrequencyDebounce(t *testing.T) {
+	now := time.Now()
+	cache := New(2, time.Hour, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithFrequencyDebounce[string, int](time.Second))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	for i := 0; i < 100; i++ {
+		cache.Get("a")
+	}
+	if f := frequencyOf(cache, "a"); f != 1 {
+		t.Errorf("Expected frequency 1 within the window, got %d", f)
+	}
+
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second)
+		for j := 0; j < 100; j++ {
+			cache.Get("a")
+		}
+	}
+	if f := frequencyOf(cache, "a"); f != 6 {
+		t.Errorf("Expected frequency 6 after five windows, got %d", f)
+	}
+}
+
+// Test the min frequency observer sees the lowest frequency move
+func TestMinFreqObserver(t *testing.T) {
+	var changes [][2]int
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithMinFreqObserver[string, int](func(old, new int) {
+			changes = append(changes, [2]int{old, new})
+		}))
+
+	cache.Set("a", 1) // 0 -> 1
+	cache.Get("a")    // 1 -> 2
+	cache.Set("b", 2) // 2 -> 1
+	cache.Get("b")    // 1 -> 2
+	cache.Delete("a") // unchanged
+	cache.Delete("b") // 2 -> 0
+
+	want := [][2]int{{0, 1}, {1, 2}, {2, 1}, {1, 2}, {2, 0}}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("Expected changes %v, got %v", want, changes)
+	}
+}
+
+// Test AccessCount keeps counting reads while the frequency decays
+func TestAccessCount(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithFrequencyDecay[string, int](time.Hour, 0.5))
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	for i := 0; i < 8; i++ {
+		cache.Get("a")
+	}
+	cache.decayFrequencies()
+
+	if n, ok := cache.AccessCount("a"); !ok || n != 8 {
+		t.Errorf("Expected 8 reads, got %d", n)
+	}
+	if f := frequencyOf(cache, "a"); f >= 8 {
+		t.Errorf("Expected the frequency to have decayed, got %d", f)
+	}
+	if _, ok := cache.AccessCount("missing"); ok {
+		t.Errorf("Expected no count for a missing key")
+	}
+}
+
+// Test WithMaxBucketSize bounds a single frequency bucket
+func TestMaxBucketSize(t *testing.T) {
+	cache := newTestCache[int, int](100, time.Minute, nil, WithMaxBucketSize[int, int](10))
+	cache.Set(-1, 0)
+	cache.Get(-1)
+	for i := 0; i < 50; i++ {
+		cache.Set(i, i)
+	}
+
+	if n := cache.Len(); n != 11 {
+		t.Errorf("Expected 10 frequency-1 entries plus the hot one, got %d", n)
+	}
+	if !cache.Contains(-1) || !cache.Contains(49) || cache.Contains(39) {
+		t.Errorf("Expected the oldest frequency-1 entries to be evicted")
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
+
+// Test exported priority can be transformed and imported back
+func TestExportImportPriority(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("c")
+	cache.Get("c")
+	cache.Get("b")
+
+	p := cache.ExportPriority()
+	want := []KeyFreq[string]{{"a", 1}, {"b", 2}, {"c", 3}}
+	if fmt.Sprint(p) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, p)
+	}
+
+	// Reverse the priority, mentioning a key that is gone
+	cache.Delete("b")
+	cache.ImportPriority([]KeyFreq[string]{{"c", 1}, {"b", 5}, {"a", 3}})
+	want = []KeyFreq[string]{{"c", 1}, {"a", 3}}
+	if p := cache.ExportPriority(); fmt.Sprint(p) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, p)
+	}
+
+	cache.Set("d", 4)
+	cache.ImportPriority([]KeyFreq[string]{{"a", 1}, {"c", 1}})
+	want = []KeyFreq[string]{{"d", 1}, {"a", 1}, {"c", 1}}
+	if p := cache.ExportPriority(); fmt.Sprint(p) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, p)
+	}
+	cache.Set("e", 5)
+	cache.Set("f", 6)
+	if _, ok := cache.Get("d"); ok {
+		t.Errorf("Expected d to be evicted first")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Errorf("Expected c to survive")
+	}
+}
+
+// Test a key popular long ago loses priority to a recently popular one
+func TestWindow(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithWindow[string, int](time.Hour, 3))
+	cache.Set("old", 1)
+	for i := 0; i < 10; i++ {
+		cache.Get("old")
+	}
+	cache.rotateWindow()
+	cache.Set("new", 2)
+	for i := 0; i < 3; i++ {
+		cache.Get("new")
+	}
+
+	cache.rotateWindow()
+	if f := frequencyOf(cache, "old"); f != 11 {
+		t.Errorf("Expected old to keep frequency 11 within the window, got %d", f)
+	}
+	cache.rotateWindow()
+	if f := frequencyOf(cache, "old"); f != 1 {
+		t.Errorf("Expected old to drop to frequency 1 once its bucket aged out, got %d", f)
+	}
+	if f := frequencyOf(cache, "new"); f != 4 {
+		t.Errorf("Expected new to keep frequency 4, got %d", f)
+	}
+
+	cache.Set("next", 3)
+	if _, ok := cache.Get("old"); ok {
+		t.Errorf("Expected old to be evicted")
+	}
+	if _, ok := cache.Get("new"); !ok {
+		t.Errorf("Expected new to survive")
+	}
+}
+
+// Test repeated decay keeps the buckets dense without reordering evictions
+func TestDecayKeepsBucketsDense(t *testing.T) {
+	cache := newTestCache[int, int](10, time.Minute, nil,
+		WithFrequencyDecay[int, int](time.Hour, 0.3))
+	for i := 0; i < 10; i++ {
+		cache.Set(i, i)
+	}
+
+	keysOf := func(p []KeyFreq[int]) []int {
+		keys := make([]int, len(p))
+		for i, kf := range p {
+			keys[i] = kf.Key
+		}
+		return keys
+	}
+	for cycle := 0; cycle < 50; cycle++ {
+		for i := 0; i < 10; i++ {
+			for j := 0; j < i*cycle%17; j++ {
+				cache.Get(i)
+			}
+		}
+		before := keysOf(cache.ExportPriority())
+		cache.decayFrequencies()
+		after := cache.ExportPriority()
+		if fmt.Sprint(keysOf(after)) != fmt.Sprint(before) {
+			t.Fatalf("Expected decay to keep the eviction order %v, got %v", before, keysOf(after))
+		}
+		if n, top := cache.BucketCount(), after[len(after)-1].Frequency; n > 10 || top != n {
+			t.Fatalf("Expected up to 10 buckets numbered 1..%d, got a top frequency of %d", n, top)
+		}
+	}
+}
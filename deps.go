@@ -0,0 +1,205 @@
+package lfu
+
+import "errors"
+
+// ErrDependencyCycle is returned by SetWithDependencies when key would end
+// up depending on itself.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// SetWithDependencies inserts or updates key like Set and records that its
+// value was derived from the keys in dependsOn, replacing any dependencies
+// it had. Any later change or removal of a dependency, such as a Set,
+// Delete or Take, removes key as if deleted, along with evicted copies of
+// it kept for WithVictimCache or WithSpillover, and in turn whatever
+// depends on key. Dependencies need not be cached themselves. A plain Set keeps the key's existing dependencies.
+// If key already is, directly or not, a dependency of one of dependsOn,
+// nothing is stored and ErrDependencyCycle is returned; other errors are
+// those of SetWithError.
+func (c *LFUCache[K, V]) SetWithDependencies(key K, value V, dependsOn ...K) error {
+	// Check before the writer sees the value, and again under the lock
+	c.rlock()
+	cycle := c.cycles(key, dependsOn)
+	c.mu.RUnlock()
+	if cycle {
+		return ErrDependencyCycle
+	}
+	err := c.writeLocked(key, value, func() {
+		if cycle = c.cycles(key, dependsOn); cycle {
+			return
+		}
+		c.set(key, value)
+		ent, ok := c.keyMap[key]
+		if !ok {
+			return
+		}
+		c.undepend(ent)
+		ent.dependsOn = nil
+		seen := make(map[K]struct{}, len(dependsOn))
+		for _, dep := range dependsOn {
+			if _, dup := seen[dep]; !dup {
+				seen[dep] = struct{}{}
+				ent.dependsOn = append(ent.dependsOn, dep)
+			}
+		}
+		c.indexDependencies(ent)
+	})
+	if err == nil && cycle {
+		return ErrDependencyCycle
+	}
+	return err
+}
+
+// cycles reports whether making key depend on dependsOn would create a
+// cycle. Must be called with c.mu held.
+func (c *LFUCache[K, V]) cycles(key K, dependsOn []K) bool {
+	for _, dep := range dependsOn {
+		if dep == key || c.dependsOn(dep, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependsOn reports whether the cached entry for key depends, directly or
+// not, on target. Must be called with c.mu held.
+func (c *LFUCache[K, V]) dependsOn(key, target K) bool {
+	visited := map[K]struct{}{key: {}}
+	stack := []K{key}
+	for len(stack) > 0 {
+		ent, ok := c.keyMap[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !ok {
+			continue
+		}
+		for _, dep := range ent.dependsOn {
+			if dep == target {
+				return true
+			}
+			if _, ok := visited[dep]; !ok {
+				visited[dep] = struct{}{}
+				stack = append(stack, dep)
+			}
+		}
+	}
+	return false
+}
+
+// invalidateDependents removes the entries depending on key, and those
+// depending on them in turn, along with their evicted copies kept in the
+// victim cache or the spill store. Every removal or change of a value goes
+// through it. Must be called with c.mu held.
+func (c *LFUCache[K, V]) invalidateDependents(key K) {
+	if c.dependents == nil {
+		return
+	}
+	stack := []K{key}
+	for len(stack) > 0 {
+		dep := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for dependent := range c.dependents[dep] {
+			if c.isParked(dependent) {
+				c.dropVictim(dependent) // also drops it from c.dependents[dep]
+				stack = append(stack, dependent)
+				continue
+			}
+			ent, ok := c.keyMap[dependent]
+			if !ok {
+				delete(c.dependents[dep], dependent)
+				continue
+			}
+			c.unlink(ent) // also drops it from c.dependents[dep]
+			c.emit(OpDelete, ent)
+			c.queueClose(ent.value)
+			stack = append(stack, dependent)
+		}
+	}
+}
+
+// moveDependents makes the entries depending on oldKey, and their evicted
+// copies, depend on newKey instead. newKey must have no dependents. Must
+// be called with c.mu held.
+func (c *LFUCache[K, V]) moveDependents(oldKey, newKey K) {
+	keys, ok := c.dependents[oldKey]
+	if !ok {
+		return
+	}
+	for key := range keys {
+		for _, ent := range c.copiesOf(key) {
+			for i, dep := range ent.dependsOn {
+				if dep == oldKey {
+					ent.dependsOn[i] = newKey
+				}
+			}
+		}
+	}
+	delete(c.dependents, oldKey)
+	c.dependents[newKey] = keys
+}
+
+// copiesOf returns the live entry for key and its evicted copies kept in
+// the victim cache and the spill store. Must be called with c.mu held.
+func (c *LFUCache[K, V]) copiesOf(key K) []*entry[K, V] {
+	var copies []*entry[K, V]
+	if ent, ok := c.keyMap[key]; ok {
+		copies = append(copies, ent)
+	}
+	if c.victims != nil {
+		if e, ok := c.victims.index[key]; ok {
+			copies = append(copies, e.Value.(*entry[K, V]))
+		}
+	}
+	if ent, ok := c.spilled[key]; ok {
+		copies = append(copies, ent)
+	}
+	return copies
+}
+
+// isParked reports whether an evicted copy of key is kept in the victim
+// cache or the spill store. Must be called with c.mu held.
+func (c *LFUCache[K, V]) isParked(key K) bool {
+	if _, ok := c.spilled[key]; ok {
+		return true
+	}
+	if c.victims == nil {
+		return false
+	}
+	_, ok := c.victims.index[key]
+	return ok
+}
+
+// unpark drops ent, an evicted copy just removed from the victim cache or
+// the spill store, from the dependency index unless another copy is kept.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) unpark(ent *entry[K, V]) {
+	if ent.dependsOn != nil && !c.isParked(ent.key) {
+		c.undepend(ent)
+	}
+}
+
+// indexDependencies records ent as a dependent of each of its
+// dependencies. Must be called with c.mu held.
+func (c *LFUCache[K, V]) indexDependencies(ent *entry[K, V]) {
+	if c.dependents == nil && len(ent.dependsOn) > 0 {
+		c.dependents = make(map[K]map[K]struct{})
+	}
+	for _, dep := range ent.dependsOn {
+		keys := c.dependents[dep]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			c.dependents[dep] = keys
+		}
+		keys[ent.key] = struct{}{}
+	}
+}
+
+// undepend drops ent from the dependency index, leaving ent.dependsOn as
+// it is. Must be called with c.mu held.
+func (c *LFUCache[K, V]) undepend(ent *entry[K, V]) {
+	for _, dep := range ent.dependsOn {
+		keys := c.dependents[dep]
+		delete(keys, ent.key)
+		if len(keys) == 0 {
+			delete(c.dependents, dep)
+		}
+	}
+}
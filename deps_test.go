@@ -0,0 +1,186 @@
+package lfu
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test writes of a dependency cascade to its dependents
+func TestSetWithDependencies(t *testing.T) {
+	cache := newTestCache[string, int](8, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.SetWithDependencies("b", 2, "a")
+	cache.SetWithDependencies("c", 3, "b")
+	cache.SetWithDependencies("d", 4, "a", "x")
+	cache.Set("e", 5)
+
+	cache.Set("a", 10)
+	for _, key := range []string{"b", "c", "d"} {
+		if cache.Contains(key) {
+			t.Errorf("Expected %s to be invalidated by the update of a", key)
+		}
+	}
+	if !cache.Contains("a") || !cache.Contains("e") {
+		t.Errorf("Expected a and e to be kept")
+	}
+
+	cache.SetWithDependencies("b", 2, "a")
+	cache.SetWithDependencies("c", 3, "b")
+	cache.Set("b", 20) // keeps b's dependency on a
+	if cache.Contains("c") {
+		t.Errorf("Expected c to be invalidated by the update of b")
+	}
+	cache.Delete("a")
+	if cache.Contains("b") {
+		t.Errorf("Expected b to be invalidated by the delete of a")
+	}
+
+	cache.SetWithDependencies("d", 4, "x")
+	cache.Take("x")
+	if cache.Contains("d") {
+		t.Errorf("Expected d to be invalidated by taking x, even though x was never cached")
+	}
+	if len(cache.dependents) != 0 {
+		t.Errorf("Expected the dependency index to be empty, got %v", cache.dependents)
+	}
+}
+
+// Test removed dependents leave the dependency index
+func TestDependenciesEviction(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.SetWithDependencies("b", 2, "a")
+	cache.Set("c", 3) // evicts b
+	if len(cache.dependents) != 0 {
+		t.Errorf("Expected the evicted b to leave the index, got %v", cache.dependents)
+	}
+	cache.Set("a", 10)
+	if !cache.Contains("c") {
+		t.Errorf("Expected c to be unaffected by the update of a")
+	}
+}
+
+// Test dependencies that would form a cycle are refused
+func TestDependencyCycle(t *testing.T) {
+	cache := newTestCache[string, int](8, time.Minute, nil)
+	cache.SetWithDependencies("b", 2, "a")
+	cache.SetWithDependencies("c", 3, "b")
+
+	if err := cache.SetWithDependencies("a", 1, "c"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected ErrDependencyCycle, got %v", err)
+	}
+	if err := cache.SetWithDependencies("d", 4, "d"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected ErrDependencyCycle for a self-dependency, got %v", err)
+	}
+	if cache.Contains("a") || cache.Contains("d") {
+		t.Errorf("Expected nothing to be stored on a cycle")
+	}
+	if err := cache.SetWithDependencies("a", 1, "x"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if cache.Contains("b") || cache.Contains("c") {
+		t.Errorf("Expected setting a to invalidate b and c")
+	}
+}
+
+// Test Rename keeps the dependency index consistent
+func TestDependenciesRename(t *testing.T) {
+	cache := newTestCache[string, int](8, time.Minute, nil)
+	cache.SetWithDependencies("b", 2, "a")
+	cache.SetWithDependencies("d", 4, "b")
+	cache.Rename("b", "c")
+	if err := cache.checkInvariants(); err != nil {
+		t.Fatalf("Expected a consistent index after Rename, got %v", err)
+	}
+
+	cache.Delete("a") // used to panic on the stale index entry of b
+	if cache.Contains("c") || cache.Contains("d") {
+		t.Errorf("Expected c and its dependent d to be invalidated with a")
+	}
+
+	cache.SetWithDependencies("c", 3, "a")
+	cache.SetWithDependencies("e", 5, "x")
+	cache.SetWithDependencies("f", 6, "e")
+	cache.Rename("c", "e") // overwrites e, invalidating f
+	if cache.Contains("f") || !cache.Contains("e") {
+		t.Errorf("Expected e to replace the old e and its dependent f to be invalidated")
+	}
+	cache.Set("x", 0)
+	if !cache.Contains("e") {
+		t.Errorf("Expected the renamed e to depend on a, not x")
+	}
+	cache.Set("a", 0)
+	if cache.Contains("e") {
+		t.Errorf("Expected e to be invalidated with a")
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent index, got %v", err)
+	}
+}
+
+// Test evicted dependents kept aside are dropped when a dependency changes
+func TestDependenciesEvictedCopies(t *testing.T) {
+	parked := map[string]Option[string, int]{
+		"victim cache": WithVictimCache[string, int](4),
+		"spill store":  WithSpillover[string, int](&mapSpillStore{data: map[string]int{}}),
+	}
+	for name, opt := range parked {
+		cache := newTestCache[string, int](2, time.Minute, nil, opt)
+		cache.Set("a", 1)
+		cache.Get("a")
+		cache.SetWithDependencies("b", 10, "a")
+		cache.Set("c", 3) // evicts b
+		if err := cache.checkInvariants(); err != nil {
+			t.Fatalf("%s: expected a consistent index, got %v", name, err)
+		}
+
+		cache.Set("a", 2)
+		if v, ok := cache.Get("b"); ok {
+			t.Errorf("%s: expected b to be invalidated with a, got %d", name, v)
+		}
+		if err := cache.checkInvariants(); err != nil {
+			t.Errorf("%s: expected a consistent index, got %v", name, err)
+		}
+	}
+}
+
+// Test a promoted dependent keeps its dependencies
+func TestDependenciesPromoted(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithSpillover[string, int](&mapSpillStore{data: map[string]int{}}))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.SetWithDependencies("b", 10, "a")
+	cache.Set("c", 3) // spills b
+	if v, ok := cache.Get("b"); !ok || v != 10 {
+		t.Fatalf("Expected b=10 back from the spill store, got %d", v)
+	}
+	cache.Set("a", 2)
+	if cache.Contains("b") {
+		t.Errorf("Expected the promoted b to still depend on a")
+	}
+}
+
+// Test removing or replacing a dependency by any means invalidates its dependents
+func TestDependenciesOtherWrites(t *testing.T) {
+	writes := map[string]func(c *LFUCache[string, int]){
+		"Compute delete": func(c *LFUCache[string, int]) {
+			c.Compute("a", func(int, bool) (int, bool) { return 0, false })
+		},
+		"UpdateAll": func(c *LFUCache[string, int]) {
+			c.UpdateAll(func(k string, v int) (int, bool) { return v + 1, true })
+		},
+		"InvalidateTag": func(c *LFUCache[string, int]) { c.InvalidateTag("t") },
+	}
+	for name, write := range writes {
+		cache := newTestCache[string, int](4, time.Minute, nil)
+		cache.SetWithTags("a", 1, "t")
+		cache.SetWithDependencies("b", 10, "a")
+		write(cache)
+		if v, ok := cache.Get("b"); ok {
+			t.Errorf("%s: expected b to be invalidated, got %d", name, v)
+		}
+	}
+}
@@ -2,44 +2,217 @@ package lfu
 
 import (
 	"container/list"
+	"math/rand"
 	"time"
 )
 
+// TiebreakPolicy picks the victim among entries sharing the lowest frequency.
+type TiebreakPolicy int
+
+const (
+	// TiebreakLRU evicts the least recently used entry. This is the default.
+	TiebreakLRU TiebreakPolicy = iota
+	// TiebreakFIFO evicts the entry inserted into the cache first,
+	// regardless of how recently it was accessed.
+	TiebreakFIFO
+	// TiebreakRandom evicts a random entry.
+	TiebreakRandom
+)
+
 // entry represents a cache item.
 type entry[K comparable, V any] struct {
 	key       K
 	value     V
 	frequency int
 	node      *list.Element
-	createdAt time.Time
+	createdAt time.Time // keeps the monotonic reading of the default clock
+	expiresAt time.Time // set by SetWithDeadline, otherwise the TTL applies
+	seq       uint64    // insertion order
+	slot      int       // position in a compact freqList
+
+	accessCount int64 // reads, unaffected by decay and merging
+
+	lastAccess time.Time // last counted access, for WithFrequencyDebounce
+	touchedAt  time.Time // last access, for WithSlidingTTL
+
+	decayWeight float64  // multiplier applied to frequency decay
+	tags        []string // set by SetWithTags
+	dependsOn   []K      // set by SetWithDependencies
+	memSize     int64    // estimate kept under WithApproxMemoryLimit
+	window      []int    // accesses per time bucket under WithWindow
 }
 
 // freqList maintains a list of entries for a particular frequency.
+// Compact lists, used for frequency 1 under WithCompactLowFrequency, keep
+// their entries in a slice instead of allocating a list node per entry.
 type freqList[K comparable, V any] struct {
-	items *list.List // list of *entry[K, V]
+	items *list.List // list of *entry[K, V], nil when compact
+
+	slots []*entry[K, V] // oldest first, nil where removed
+	head  int            // slots before head are all nil
+	live  int            // non-nil slots
 }
 
 func newFreqList[K comparable, V any]() *freqList[K, V] {
 	return &freqList[K, V]{items: list.New()}
 }
 
+func newCompactFreqList[K comparable, V any]() *freqList[K, V] {
+	return &freqList[K, V]{}
+}
+
 func (f *freqList[K, V]) pushFront(e *entry[K, V]) {
-	e.node = f.items.PushFront(e)
+	if f.items != nil {
+		e.node = f.items.PushFront(e)
+		return
+	}
+	e.node = nil
+	e.slot = len(f.slots)
+	f.slots = append(f.slots, e)
+	f.live++
+}
+
+func (f *freqList[K, V]) pushBack(e *entry[K, V]) {
+	if f.items != nil {
+		e.node = f.items.PushBack(e)
+		return
+	}
+	e.node = nil
+	if f.head > 0 {
+		f.head--
+		f.slots[f.head] = e
+		e.slot = f.head
+	} else {
+		f.slots = append([]*entry[K, V]{e}, f.slots...)
+		f.reslot()
+	}
+	f.live++
+}
+
+func (f *freqList[K, V]) moveToFront(e *entry[K, V]) {
+	if f.items != nil {
+		f.items.MoveToFront(e.node)
+		return
+	}
+	f.remove(e)
+	f.pushFront(e)
 }
 
 func (f *freqList[K, V]) remove(e *entry[K, V]) {
-	f.items.Remove(e.node)
+	if f.items != nil {
+		f.items.Remove(e.node)
+		return
+	}
+	f.slots[e.slot] = nil
+	f.live--
+	for f.head < len(f.slots) && f.slots[f.head] == nil {
+		f.head++
+	}
+	// Drop the holes once they outnumber the live entries
+	if holes := len(f.slots) - f.head - f.live; holes > f.live && holes > 32 || f.live == 0 {
+		live := make([]*entry[K, V], 0, f.live)
+		for _, ent := range f.slots[f.head:] {
+			if ent != nil {
+				live = append(live, ent)
+			}
+		}
+		f.slots, f.head = live, 0
+		f.reslot()
+	}
+}
+
+// reslot updates the slot index of every entry of a compact list.
+func (f *freqList[K, V]) reslot() {
+	for i, ent := range f.slots {
+		if ent != nil {
+			ent.slot = i
+		}
+	}
+}
+
+// victim returns the entry chosen by policy without removing it.
+// The list is ordered most recently used first.
+func (f *freqList[K, V]) victim(policy TiebreakPolicy) *entry[K, V] {
+	var victim *entry[K, V]
+	switch policy {
+	case TiebreakFIFO: // O(n) scan for the earliest insertion
+		f.eachOldest(func(ent *entry[K, V]) bool {
+			if victim == nil || ent.seq < victim.seq {
+				victim = ent
+			}
+			return true
+		})
+	case TiebreakRandom:
+		if f.len() == 0 {
+			return nil
+		}
+		i := rand.Intn(f.len())
+		f.eachOldest(func(ent *entry[K, V]) bool {
+			victim = ent
+			i--
+			return i >= 0
+		})
+	default:
+		f.eachOldest(func(ent *entry[K, V]) bool {
+			victim = ent
+			return false
+		})
+	}
+	return victim
+}
+
+// eachNewest calls fn for each entry, most recently used first, until fn
+// returns false.
+func (f *freqList[K, V]) eachNewest(fn func(*entry[K, V]) bool) {
+	if f.items != nil {
+		for e := f.items.Front(); e != nil; e = e.Next() {
+			if !fn(e.Value.(*entry[K, V])) {
+				return
+			}
+		}
+		return
+	}
+	for i := len(f.slots) - 1; i >= f.head; i-- {
+		if ent := f.slots[i]; ent != nil && !fn(ent) {
+			return
+		}
+	}
+}
+
+// eachOldest calls fn for each entry, least recently used first, until fn
+// returns false. fn may move the entries it is given to other lists.
+func (f *freqList[K, V]) eachOldest(fn func(*entry[K, V]) bool) {
+	if f.items != nil {
+		for e := f.items.Back(); e != nil; e = e.Prev() {
+			if !fn(e.Value.(*entry[K, V])) {
+				return
+			}
+		}
+		return
+	}
+	for _, ent := range f.slots[f.head:] {
+		if ent != nil && !fn(ent) {
+			return
+		}
+	}
+}
+
+// owns reports whether e is linked into f, as far as e's own bookkeeping
+// can tell.
+func (f *freqList[K, V]) owns(e *entry[K, V]) bool {
+	if f.items != nil {
+		return e.node != nil && e.node.Value == e
+	}
+	return e.node == nil && e.slot >= f.head && e.slot < len(f.slots) && f.slots[e.slot] == e
 }
 
-func (f *freqList[K, V]) removeOldest() *entry[K, V] {
-	elem := f.items.Back()
-	if elem == nil {
-		return nil
+func (f *freqList[K, V]) len() int {
+	if f.items != nil {
+		return f.items.Len()
 	}
-	f.items.Remove(elem)
-	return elem.Value.(*entry[K, V])
+	return f.live
 }
 
 func (f *freqList[K, V]) isEmpty() bool {
-	return f.items.Len() == 0
+	return f.len() == 0
 }
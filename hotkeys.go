@@ -0,0 +1,76 @@
+package lfu
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// hotKeyTracker counts Gets per key over fixed intervals. It has its own
+// lock so counting doesn't need the cache's.
+type hotKeyTracker[K comparable] struct {
+	mu       sync.Mutex
+	interval time.Duration
+	start    time.Time // of the current interval
+	counts   map[K]int // Gets in the current interval
+	last     map[K]int // Gets in the previous interval
+}
+
+// count records a Get of key at now.
+func (h *hotKeyTracker[K]) count(key K, now time.Time) {
+	h.mu.Lock()
+	h.rotate(now)
+	h.counts[key]++
+	h.mu.Unlock()
+}
+
+// rotate starts a new interval once the current one is over. Must be
+// called with h.mu held.
+func (h *hotKeyTracker[K]) rotate(now time.Time) {
+	elapsed := now.Sub(h.start)
+	if elapsed < h.interval {
+		return
+	}
+	if elapsed < 2*h.interval {
+		h.last = h.counts
+	} else {
+		h.last = nil // the previous interval saw no Gets at all
+	}
+	h.counts = make(map[K]int, len(h.last))
+	h.start = now
+}
+
+// HotKeys returns the keys read at least threshold times within the
+// current or the previous interval of WithHotKeyTracking, hottest first.
+// Values of such keys are worth caching closer to their readers, since
+// every Get of them contends for the same lock. Without hot key tracking
+// it returns nil.
+func (c *LFUCache[K, V]) HotKeys(threshold int) []K {
+	h := c.hot
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	h.rotate(c.clock())
+	peak := make(map[K]int)
+	for _, counts := range []map[K]int{h.last, h.counts} {
+		for key, n := range counts {
+			if n >= threshold && n > peak[key] {
+				peak[key] = n
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	keys := make([]K, 0, len(peak))
+	for key := range peak {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if peak[keys[i]] != peak[keys[j]] {
+			return peak[keys[i]] > peak[keys[j]]
+		}
+		return lessKey(keys[i], keys[j])
+	})
+	return keys
+}
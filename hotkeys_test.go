@@ -0,0 +1,55 @@
+package lfu
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test a hammered key shows up in HotKeys until its interval has passed
+func TestHotKeys(t *testing.T) {
+	now := time.Now()
+	cache := New(4, time.Hour, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithHotKeyTracking[string, int](time.Second),
+	)
+	defer cache.Stop()
+	cache.Set("hot", 1)
+	cache.Set("warm", 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cache.Get("hot")
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		cache.Get("warm")
+	}
+	cache.Get("cold")
+
+	if keys := cache.HotKeys(100); fmt.Sprint(keys) != "[hot]" {
+		t.Errorf("Expected [hot], got %v", keys)
+	}
+	if keys := cache.HotKeys(10); fmt.Sprint(keys) != "[hot warm]" {
+		t.Errorf("Expected [hot warm], got %v", keys)
+	}
+
+	// The previous interval still counts
+	now = now.Add(time.Second)
+	cache.Get("warm")
+	if keys := cache.HotKeys(100); fmt.Sprint(keys) != "[hot]" {
+		t.Errorf("Expected [hot] from the previous interval, got %v", keys)
+	}
+
+	now = now.Add(time.Second)
+	if keys := cache.HotKeys(2); len(keys) != 0 {
+		t.Errorf("Expected no hot keys once the busy interval is over, got %v", keys)
+	}
+}
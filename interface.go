@@ -0,0 +1,19 @@
+package lfu
+
+// Cache is the set of operations shared by LFUCache, ShardedCache and
+// TieredCache. Depend on it rather than on a concrete type to be able to
+// substitute a fake in tests.
+type Cache[K comparable, V any] interface {
+	ReadOnlyCache[K, V]
+	Get(key K) (V, bool)
+	Set(key K, value V)
+	Delete(key K) bool
+	Stop()
+}
+
+var (
+	_ Cache[string, int]         = (*LFUCache[string, int])(nil)
+	_ Cache[string, int]         = (*ShardedCache[string, int])(nil)
+	_ Cache[string, int]         = (*TieredCache[string, int])(nil)
+	_ ReadOnlyCache[string, int] = readOnly[string, int]{}
+)
@@ -0,0 +1,392 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCircuitOpen is returned by GetE instead of calling the loader while
+// the circuit breaker set up by WithLoaderCircuitBreaker is open.
+var ErrCircuitOpen = errors.New("loader circuit open")
+
+// ErrStale is wrapped, along with the loader error, by the error GetE
+// returns when it serves an expired value under WithServeStaleOnError.
+var ErrStale = errors.New("served stale value")
+
+// ErrLoaderPanic is wrapped by the error returned when a loader, compute
+// function, factory or batchLoader panics, so that the panic doesn't leave
+// callers waiting on the load forever.
+var ErrLoaderPanic = errors.New("loader panicked")
+
+// ErrComputeTimeout is returned to callers waiting on a load that ran past
+// the timeout set by WithComputeTimeout.
+var ErrComputeTimeout = errors.New("compute timed out")
+
+// call is a loader invocation shared by concurrent misses on the same key.
+type call[V any] struct {
+	done       chan struct{} // closed once value and err are set
+	started    time.Time
+	value      V
+	err        error
+	superseded bool // a write of the key landed while loading
+}
+
+// GetE is like Get but loads missing keys through the configured loader,
+// or a factory registered with SetFactory, returning any loader error.
+// Without a loader the error is nil unless the cache was stopped in strict
+// mode, in which case it is ErrCacheStopped.
+func (c *LFUCache[K, V]) GetE(key K) (V, bool, error) {
+	if c.rejectStopped() {
+		var zero V
+		return zero, false, ErrCacheStopped
+	}
+	var start time.Time
+	if c.observeTiming != nil {
+		start = time.Now()
+	}
+	value, ok := c.lookup(key)
+	if c.observeTiming != nil {
+		c.observeTiming("get", time.Since(start))
+	}
+	if ok {
+		return c.copyOut(value), true, nil
+	}
+	value, handled, err := c.loadFactory(key)
+	if !handled {
+		if c.loader == nil {
+			return value, false, nil
+		}
+		value, err = c.load(key, c.loadThrough)
+	}
+	if err != nil {
+		if stale, ok := c.staleValue(key); ok {
+			return c.copyOut(stale), true, fmt.Errorf("%w: %w", ErrStale, err)
+		}
+		var zero V
+		return zero, false, err
+	}
+	return c.copyOut(value), true, nil
+}
+
+// staleValue returns the value of key if it expired no longer than
+// maxStale ago.
+func (c *LFUCache[K, V]) staleValue(key K) (V, bool) {
+	c.rlock()
+	defer c.mu.RUnlock()
+	if ent, ok := c.keyMap[key]; ok {
+		if overdue := c.overdue(ent, c.clock()); overdue > 0 && overdue <= c.maxStale {
+			return ent.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// GetOrCompute returns the cached value of key, calling compute to build
+// and cache it on a miss. Concurrent callers missing the same key share
+// one compute call and its result. A Set of key that lands while compute
+// runs wins: its value is kept and returned to all callers instead of
+// the computed one.
+func (c *LFUCache[K, V]) GetOrCompute(key K, compute func() (V, error)) (V, error) {
+	if c.rejectStopped() {
+		var zero V
+		return zero, ErrCacheStopped
+	}
+	if value, ok := c.lookup(key); ok {
+		return c.copyOut(value), nil
+	}
+	value, err := c.load(key, func(K) (V, error) { return compute() })
+	if err != nil {
+		return value, err
+	}
+	return c.copyOut(value), nil
+}
+
+// load runs fn once per key across concurrent callers and caches a
+// successful result.
+func (c *LFUCache[K, V]) load(key K, fn func(K) (V, error)) (V, error) {
+	c.loadMu.Lock()
+	if cl, ok := c.loads[key]; ok {
+		c.loadMu.Unlock()
+		return c.wait(cl)
+	}
+	cl := newCall[V]()
+	c.loads[key] = cl
+	c.loadMu.Unlock()
+	return c.run(key, cl, fn)
+}
+
+// loadFactory loads key through its factory, if one is registered, or
+// joins a load of key that is already in flight, and reports whether it
+// did either. The factory is looked up and the load registered under both
+// locks, so that concurrent misses run the factory at most once.
+func (c *LFUCache[K, V]) loadFactory(key K) (value V, handled bool, err error) {
+	c.rlock()
+	c.loadMu.Lock()
+	if cl, ok := c.loads[key]; ok {
+		c.loadMu.Unlock()
+		c.mu.RUnlock()
+		value, err = c.wait(cl)
+		return value, true, err
+	}
+	factory := c.factories[key]
+	if factory == nil {
+		c.loadMu.Unlock()
+		c.mu.RUnlock()
+		return value, false, nil
+	}
+	cl := newCall[V]()
+	c.loads[key] = cl
+	c.loadMu.Unlock()
+	c.mu.RUnlock()
+	value, err = c.run(key, cl, func(K) (V, error) { return factory(), nil })
+	return value, true, err
+}
+
+// run calls fn for the load cl registered for key and finishes it, in the
+// background if a compute timeout applies.
+func (c *LFUCache[K, V]) run(key K, cl *call[V], fn func(K) (V, error)) (V, error) {
+	run := func() {
+		cl.value, cl.err = safeLoad(fn, key)
+		c.finishLoads(map[K]*call[V]{key: cl})
+	}
+	// Without locking a background load would race with the caller
+	if c.computeTimeout <= 0 || c.unlocked {
+		run()
+		return cl.value, cl.err
+	}
+	go run()
+	return c.wait(cl)
+}
+
+// safeLoad calls fn, turning a panic into an error.
+func safeLoad[K comparable, V any](fn func(K) (V, error), key K) (value V, err error) {
+	defer recovered(&err)
+	return fn(key)
+}
+
+// recovered stores a panic of the function deferring it in *err, wrapped
+// in ErrLoaderPanic.
+func recovered(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+	}
+}
+
+func newCall[V any]() *call[V] {
+	return &call[V]{done: make(chan struct{}), started: time.Now()}
+}
+
+// wait returns the result of cl once it is done, or ErrComputeTimeout
+// once it has run for longer than the compute timeout.
+func (c *LFUCache[K, V]) wait(cl *call[V]) (V, error) {
+	if c.computeTimeout <= 0 {
+		<-cl.done
+		return cl.value, cl.err
+	}
+	timer := time.NewTimer(time.Until(cl.started.Add(c.computeTimeout)))
+	defer timer.Stop()
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-timer.C:
+		var zero V
+		return zero, ErrComputeTimeout
+	}
+}
+
+// InFlightLoads returns how many keys are being loaded or computed right
+// now, including loads that ran past the compute timeout.
+func (c *LFUCache[K, V]) InFlightLoads() int {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	return len(c.loads)
+}
+
+// finishLoads caches the successful results of calls and releases their
+// waiters. A write of the key that landed while it was loading wins over
+// the loaded value, which is then replaced by the written one so that all
+// callers see the same value. Loaded values are not written back through
+// the writer, since they came from the backing store.
+func (c *LFUCache[K, V]) finishLoads(calls map[K]*call[V]) {
+	c.lock()
+	c.loadMu.Lock()
+	for key := range calls {
+		delete(c.loads, key)
+	}
+	c.loadMu.Unlock()
+	for key, cl := range calls {
+		switch {
+		case cl.err != nil:
+		case cl.superseded:
+			if ent, ok := c.keyMap[key]; ok {
+				cl.value = ent.value
+			}
+		case c.deleteOnZero == nil || !c.deleteOnZero(cl.value):
+			c.set(key, cl.value)
+		}
+	}
+	c.unlock()
+	for _, cl := range calls {
+		close(cl.done)
+	}
+	if c.utilAlert != nil {
+		c.checkUtilization()
+	}
+}
+
+// supersedeLoad marks an in-flight load of key as overtaken by a write.
+// Must be called with c.mu held.
+func (c *LFUCache[K, V]) supersedeLoad(key K) {
+	c.loadMu.Lock()
+	if cl, ok := c.loads[key]; ok {
+		cl.superseded = true
+	}
+	c.loadMu.Unlock()
+}
+
+// GetMulti returns the cached values for keys and loads the missing ones
+// with a single batchLoader call, caching what it returns. Keys that are
+// already being loaded by another GetMulti or GetE are waited for instead
+// of being loaded again. Keys the loader doesn't return are left out of
+// the result. The returned map holds every value found even when an
+// error is returned.
+func (c *LFUCache[K, V]) GetMulti(keys []K, batchLoader func(missing []K) (map[K]V, error)) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	var missing []K
+	for _, key := range keys {
+		if value, ok := c.lookup(key); ok {
+			result[key] = c.copyOut(value)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// Claim the keys nobody is loading yet and wait for the rest
+	owned := make(map[K]*call[V])
+	waiting := make(map[K]*call[V])
+	c.loadMu.Lock()
+	for _, key := range missing {
+		if cl, ok := c.loads[key]; ok {
+			waiting[key] = cl
+		} else if _, ok := owned[key]; !ok {
+			cl := newCall[V]()
+			c.loads[key] = cl
+			owned[key] = cl
+		}
+	}
+	c.loadMu.Unlock()
+
+	var firstErr error
+	if len(owned) > 0 {
+		batch := make([]K, 0, len(owned))
+		for key := range owned {
+			batch = append(batch, key)
+		}
+		loaded, err := func() (loaded map[K]V, err error) {
+			defer recovered(&err)
+			return batchLoader(batch)
+		}()
+		firstErr = err
+		for key, cl := range owned {
+			value, ok := loaded[key]
+			switch {
+			case err != nil:
+				cl.err = err
+			case !ok:
+				cl.err = ErrNotFound
+			default:
+				cl.value = value
+			}
+		}
+		c.finishLoads(owned)
+		for key, cl := range owned {
+			if cl.err == nil {
+				result[key] = c.copyOut(cl.value)
+			}
+		}
+	}
+
+	for key, cl := range waiting {
+		value, err := c.wait(cl)
+		if err == nil {
+			result[key] = c.copyOut(value)
+		} else if firstErr == nil && !errors.Is(err, ErrNotFound) {
+			firstErr = err
+		}
+	}
+	return result, firstErr
+}
+
+// loadThrough calls the loader unless the circuit breaker is open.
+func (c *LFUCache[K, V]) loadThrough(key K) (V, error) {
+	if !c.allowLoad() {
+		var zero V
+		return zero, ErrCircuitOpen
+	}
+	if c.loadSlots != nil {
+		c.loadSlots <- struct{}{}
+		defer func() { <-c.loadSlots }()
+	}
+	value, err := safeLoad(c.loader, key)
+	if err != nil {
+		c.log("load failed", "key", key, "error", err)
+	}
+	c.recordLoad(err)
+	return value, err
+}
+
+// SetFactory registers factory to build the value of key the first time
+// a Get misses it. Concurrent Gets share one factory call, whose result is
+// cached like a Set and returned as found. A Set of key discards the
+// factory, and so does its first use.
+func (c *LFUCache[K, V]) SetFactory(key K, factory func() V) {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	if c.factories == nil {
+		c.factories = make(map[K]func() V)
+	}
+	c.factories[key] = factory
+}
+
+// allowLoad reports whether the loader may be called. Once the breaker has
+// cooled down it lets a single trial load through.
+func (c *LFUCache[K, V]) allowLoad() bool {
+	if c.breakerThreshold <= 0 {
+		return true
+	}
+	c.breakerMu.Lock()
+	defer c.breakerMu.Unlock()
+	if c.loadFailures < c.breakerThreshold {
+		return true
+	}
+	if c.trialLoad || c.clock().Before(c.breakerOpenUntil) {
+		return false
+	}
+	c.trialLoad = true
+	return true
+}
+
+// recordLoad feeds a loader result to the circuit breaker.
+func (c *LFUCache[K, V]) recordLoad(err error) {
+	if c.breakerThreshold <= 0 {
+		return
+	}
+	c.breakerMu.Lock()
+	defer c.breakerMu.Unlock()
+	c.trialLoad = false
+	if err == nil {
+		c.loadFailures = 0
+		return
+	}
+	c.loadFailures++
+	if c.loadFailures >= c.breakerThreshold {
+		c.breakerOpenUntil = c.clock().Add(c.breakerCooldown)
+	}
+}
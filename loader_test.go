@@ -0,0 +1,465 @@
+package lfu
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test GetE without a loader behaves like Get
+func TestGetENoLoader(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+
+	if v, ok, err := cache.GetE("a"); !ok || v != 1 || err != nil {
+		t.Errorf("Expected (1, true, nil), got (%v, %v, %v)", v, ok, err)
+	}
+	if _, ok, err := cache.GetE("b"); ok || err != nil {
+		t.Errorf("Expected plain miss, got (%v, %v)", ok, err)
+	}
+}
+
+// Test the loader fills misses once per key and surfaces its errors
+func TestGetELoader(t *testing.T) {
+	errBackend := errors.New("backend down")
+	var calls atomic.Int32
+	loader := func(k string) (int, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		if k == "bad" {
+			return 0, errBackend
+		}
+		return len(k), nil
+	}
+	cache := newTestCache[string, int](4, time.Minute, nil, WithLoader(loader))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok, err := cache.GetE("abc"); !ok || v != 3 || err != nil {
+				t.Errorf("Expected (3, true, nil), got (%v, %v, %v)", v, ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 loader call, got %d", n)
+	}
+	if v, ok := cache.Get("abc"); !ok || v != 3 {
+		t.Errorf("Expected loaded value to be cached, got %v", v)
+	}
+
+	if _, ok, err := cache.GetE("bad"); ok || !errors.Is(err, errBackend) {
+		t.Errorf("Expected loader error, got (%v, %v)", ok, err)
+	}
+	if _, ok := cache.Get("bad"); ok {
+		t.Errorf("Expected failed load not to be cached")
+	}
+}
+
+// Test GetMulti loads only the missing keys in one batch
+func TestGetMulti(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	cache.Set("a", 1)
+
+	var batches [][]string
+	loader := func(missing []string) (map[string]int, error) {
+		batches = append(batches, missing)
+		out := make(map[string]int)
+		for _, k := range missing {
+			if k != "unknown" {
+				out[k] = len(k)
+			}
+		}
+		return out, nil
+	}
+
+	got, err := cache.GetMulti([]string{"a", "bb", "ccc", "unknown"}, loader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("Expected one batch of 3 missing keys, got %v", batches)
+	}
+	want := map[string]int{"a": 1, "bb": 2, "ccc": 3}
+	if len(got) != len(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Expected %s=%d, got %d", k, v, got[k])
+		}
+	}
+	if v, ok := cache.Get("ccc"); !ok || v != 3 {
+		t.Errorf("Expected loaded value to be cached, got %v", v)
+	}
+}
+
+// Test concurrent GetMulti calls share loads of overlapping keys
+func TestGetMultiSingleFlight(t *testing.T) {
+	cache := newTestCache[string, int](8, time.Minute, nil)
+
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	loader := func(missing []string) (map[string]int, error) {
+		time.Sleep(30 * time.Millisecond)
+		out := make(map[string]int)
+		mu.Lock()
+		for _, k := range missing {
+			loads[k]++
+			out[k] = len(k)
+		}
+		mu.Unlock()
+		return out, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, keys := range [][]string{{"a", "bb"}, {"bb", "ccc"}, {"a", "ccc"}} {
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			got, err := cache.GetMulti(keys, loader)
+			if err != nil || len(got) != 2 {
+				t.Errorf("Expected 2 values for %v, got %v (%v)", keys, got, err)
+			}
+		}(keys)
+	}
+	wg.Wait()
+
+	for k, n := range loads {
+		if n != 1 {
+			t.Errorf("Expected %s to be loaded once, got %d", k, n)
+		}
+	}
+}
+
+// Test the loader circuit breaker opens after failures and closes after a
+// successful trial load
+func TestLoaderCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	down := true
+	calls := 0
+	loader := func(k string) (int, error) {
+		calls++
+		if down {
+			return 0, errors.New("backend down")
+		}
+		return 1, nil
+	}
+	cache := New(4, time.Minute, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithLoader(loader),
+		WithLoaderCircuitBreaker[string, int](2, time.Second))
+	defer cache.Stop()
+
+	cache.GetE("a")
+	cache.GetE("b")
+	if _, _, err := cache.GetE("c"); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Errorf("Expected the breaker to open after 2 failures, got %v with %d calls", err, calls)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, _, err := cache.GetE("d"); errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Errorf("Expected a failed trial load after the cooldown, got %v with %d calls", err, calls)
+	}
+	if _, _, err := cache.GetE("e"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the failed trial to reopen the breaker, got %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	down = false
+	if v, ok, err := cache.GetE("f"); !ok || v != 1 || err != nil {
+		t.Errorf("Expected a successful trial load, got (%v, %v, %v)", v, ok, err)
+	}
+	if _, ok, err := cache.GetE("g"); !ok || err != nil {
+		t.Errorf("Expected the breaker to be closed, got %v", err)
+	}
+}
+
+// Test a key's factory runs at most once under concurrent Gets
+func TestSetFactory(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	var calls atomic.Int32
+	cache.SetFactory("lazy", func() int {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return 42
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := cache.Get("lazy"); !ok || v != 42 {
+				t.Errorf("Expected (42, true), got (%v, %v)", v, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	cache.Get("lazy")
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 factory call, got %d", n)
+	}
+
+	cache.SetFactory("set", func() int { return 1 })
+	cache.Set("set", 2)
+	cache.Delete("set")
+	if _, ok := cache.Get("set"); ok {
+		t.Errorf("Expected Set to discard the factory")
+	}
+}
+
+// Test a Set landing during a GetOrCompute call wins over the computed value
+func TestGetOrComputeSetWins(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	results := make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrCompute("key", func() (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			results <- v
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+
+	cache.Set("key", 2)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != 2 {
+			t.Errorf("Expected both callers to see 2, got %d", v)
+		}
+	}
+	if v, _ := cache.Get("key"); v != 2 {
+		t.Errorf("Expected the Set's value 2 to survive, got %d", v)
+	}
+
+	v, err := cache.GetOrCompute("other", func() (int, error) { return 3, nil })
+	if err != nil || v != 3 {
+		t.Errorf("Expected (3, nil), got (%d, %v)", v, err)
+	}
+	if v, ok := cache.Get("other"); !ok || v != 3 {
+		t.Errorf("Expected the computed value to be cached, got (%d, %v)", v, ok)
+	}
+}
+
+// Test a panicking loader fails its callers instead of blocking the key
+func TestLoaderPanic(t *testing.T) {
+	panics := true
+	cache := newTestCache(4, time.Minute, nil, WithLoader(func(string) (int, error) {
+		if panics {
+			panic("boom")
+		}
+		return 1, nil
+	}))
+
+	if _, ok, err := cache.GetE("a"); ok || !errors.Is(err, ErrLoaderPanic) {
+		t.Errorf("Expected ErrLoaderPanic, got %v, %v", ok, err)
+	}
+	_, err := cache.GetOrCompute("b", func() (int, error) { panic("boom") })
+	if !errors.Is(err, ErrLoaderPanic) {
+		t.Errorf("Expected ErrLoaderPanic from GetOrCompute, got %v", err)
+	}
+	_, err = cache.GetMulti([]string{"c"}, func([]string) (map[string]int, error) { panic("boom") })
+	if !errors.Is(err, ErrLoaderPanic) {
+		t.Errorf("Expected ErrLoaderPanic from GetMulti, got %v", err)
+	}
+	if n := cache.InFlightLoads(); n != 0 {
+		t.Errorf("Expected the panicked loads to be cleaned up, got %d", n)
+	}
+
+	panics = false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, ok, err := cache.GetE("a"); !ok || v != 1 || err != nil {
+			t.Errorf("Expected a=1 once the loader recovers, got %v, %v, %v", v, ok, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the key not to stay blocked after a panic")
+	}
+}
+
+// Test WithMaxConcurrentLoads bounds the loader calls running at once
+func TestMaxConcurrentLoads(t *testing.T) {
+	var running, peak atomic.Int32
+	cache := newTestCache[int, int](64, time.Minute, nil,
+		WithLoader[int, int](func(key int) (int, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return key, nil
+		}),
+		WithMaxConcurrentLoads[int, int](3),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			if v, ok, err := cache.GetE(key); err != nil || !ok || v != key {
+				t.Errorf("Expected (%d, true, nil), got (%d, %v, %v)", key, v, ok, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > 3 {
+		t.Errorf("Expected at most 3 concurrent loads, got %d", p)
+	}
+}
+
+// Test expired values are served when the loader fails within maxStale
+func TestServeStaleOnError(t *testing.T) {
+	now := time.Now()
+	failing := errors.New("backend down")
+	cache := New(4, time.Minute, 0, nil,
+		WithClock[string, int](func() time.Time { return now }),
+		WithLoader(func(string) (int, error) { return 0, failing }),
+		WithServeStaleOnError[string, int](time.Minute))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	now = now.Add(90 * time.Second)
+	v, ok, err := cache.GetE("a")
+	if !ok || v != 1 {
+		t.Errorf("Expected stale a=1, got %v, %v", v, ok)
+	}
+	if !errors.Is(err, ErrStale) || !errors.Is(err, failing) {
+		t.Errorf("Expected an error wrapping ErrStale and the loader error, got %v", err)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected Get to serve stale a=1, got %v, %v", v, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, err := cache.GetE("a"); ok || !errors.Is(err, failing) || errors.Is(err, ErrStale) {
+		t.Errorf("Expected the loader error past maxStale, got %v, %v", ok, err)
+	}
+}
+
+// Test callers stop waiting on a load that runs past the compute timeout
+func TestComputeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	blocked := func() (int, error) {
+		<-release
+		return 1, nil
+	}
+	cache := newTestCache(4, time.Minute, nil,
+		WithLoader(func(string) (int, error) { return blocked() }),
+		WithComputeTimeout[string, int](20*time.Millisecond))
+
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := cache.GetE("a")
+		errs <- err
+	}()
+	go func() {
+		_, err := cache.GetOrCompute("a", blocked)
+		errs <- err
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrComputeTimeout) {
+				t.Errorf("Expected ErrComputeTimeout, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected callers to time out instead of blocking")
+		}
+	}
+
+	start := time.Now()
+	if _, _, err := cache.GetE("a"); !errors.Is(err, ErrComputeTimeout) || time.Since(start) > 15*time.Millisecond {
+		t.Errorf("Expected a late caller to fail at once, got %v after %v", err, time.Since(start))
+	}
+	if n := cache.InFlightLoads(); n != 1 {
+		t.Errorf("Expected the stuck load to be in flight, got %d", n)
+	}
+
+	close(release)
+	for i := 0; i < 100 && cache.InFlightLoads() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if v, ok := cache.Peek("a"); !ok || v != 1 {
+		t.Errorf("Expected the late result a=1 to be cached, got %v, %v", v, ok)
+	}
+}
+
+// Test loads run on the caller without locking despite a compute timeout
+func TestComputeTimeoutWithoutLocking(t *testing.T) {
+	cache := newTestCache(4, time.Minute, nil,
+		WithoutLocking[string, int](),
+		WithLoader(func(string) (int, error) {
+			time.Sleep(20 * time.Millisecond)
+			return 1, nil
+		}),
+		WithComputeTimeout[string, int](time.Millisecond))
+
+	if v, ok, err := cache.GetE("a"); !ok || v != 1 || err != nil {
+		t.Errorf("Expected a=1 loaded on the caller, got %v, %v, %v", v, ok, err)
+	}
+	cache.Set("b", 2)
+	if n := cache.InFlightLoads(); n != 0 {
+		t.Errorf("Expected no load left running, got %d", n)
+	}
+}
+
+// Test concurrent misses never run a factory twice, however they interleave
+func TestSetFactoryRunsOnce(t *testing.T) {
+	cache := newTestCache[int, int](1000, time.Minute, nil)
+	calls := make([]atomic.Int32, 500)
+	for key := range calls {
+		key := key
+		cache.SetFactory(key, func() int { return int(calls[key].Add(1)) })
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range calls {
+				cache.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+	for key := range calls {
+		if n := calls[key].Load(); n != 1 {
+			t.Fatalf("Expected 1 factory call for key %d, got %d", key, n)
+		}
+	}
+}
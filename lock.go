@@ -0,0 +1,149 @@
+package lfu
+
+import "time"
+
+// rwLocker is the lock guarding a cache's state: a *sync.RWMutex, or
+// noLock under WithoutLocking.
+type rwLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+	TryLock() bool
+	TryRLock() bool
+}
+
+// noLock is an rwLocker that does nothing, for caches whose callers
+// serialize access themselves.
+type noLock struct{}
+
+func (noLock) Lock()          {}
+func (noLock) Unlock()        {}
+func (noLock) RLock()         {}
+func (noLock) RUnlock()       {}
+func (noLock) TryLock() bool  { return true }
+func (noLock) TryRLock() bool { return true }
+
+// lock acquires the write lock, recording the wait when contention
+// tracking is enabled.
+func (c *LFUCache[K, V]) lock() {
+	if !c.trackContention {
+		c.mu.Lock()
+		return
+	}
+	if c.mu.TryLock() {
+		return
+	}
+	start := time.Now()
+	c.mu.Lock()
+	c.recordWait(start)
+}
+
+// rlock acquires the read lock, recording the wait when contention
+// tracking is enabled.
+func (c *LFUCache[K, V]) rlock() {
+	if !c.trackContention {
+		c.mu.RLock()
+		return
+	}
+	if c.mu.TryRLock() {
+		return
+	}
+	start := time.Now()
+	c.mu.RLock()
+	c.recordWait(start)
+}
+
+// unlock releases the write lock and then notifies the observers, runs the
+// eviction callbacks, closes the values and applies the spill store writes
+// queued while it was held, so none of this happens under the lock.
+func (c *LFUCache[K, V]) unlock() {
+	evicted, closing, spillOps := c.evicted, c.closing, c.spillOps
+	c.evicted, c.closing, c.spillOps = nil, nil, nil
+	oldMin, newMin, minChanged := c.minFreqChange()
+	emptied, filled := c.emptinessChange()
+	c.mu.Unlock()
+	if minChanged {
+		c.onMinFreq(oldMin, newMin)
+	}
+	if emptied && c.onEmpty != nil {
+		c.onEmpty()
+	}
+	if filled && c.onNonEmpty != nil {
+		c.onNonEmpty()
+	}
+	for _, ev := range evicted {
+		c.dispatch(ev)
+	}
+	for _, closer := range closing {
+		closer.Close()
+	}
+	if len(spillOps) > 0 {
+		c.runSpill(spillOps)
+	}
+}
+
+// minFreqChange reports the net change of the lowest frequency since it
+// was last observed. Must be called with c.mu held.
+func (c *LFUCache[K, V]) minFreqChange() (from, to int, changed bool) {
+	if c.onMinFreq == nil {
+		return 0, 0, false
+	}
+	if c.freqMap[c.minFreq] == nil {
+		c.resetMinFreq() // report the real minimum, not a stale one
+	}
+	if c.minFreq == c.observedMinFreq {
+		return 0, 0, false
+	}
+	from, c.observedMinFreq = c.observedMinFreq, c.minFreq
+	return from, c.minFreq, true
+}
+
+// emptinessChange reports whether the cache became empty or non-empty
+// since it was last observed. Must be called with c.mu held.
+func (c *LFUCache[K, V]) emptinessChange() (emptied, filled bool) {
+	if c.onEmpty == nil && c.onNonEmpty == nil {
+		return false, false
+	}
+	if nonEmpty := c.size > 0; nonEmpty != c.observedNonEmpty {
+		c.observedNonEmpty = nonEmpty
+		return !nonEmpty, nonEmpty
+	}
+	return false, false
+}
+
+// lockWithin tries to acquire the write lock until timeout elapses,
+// polling with a growing backoff, and reports whether it succeeded.
+func (c *LFUCache[K, V]) lockWithin(timeout time.Duration) bool {
+	if c.mu.TryLock() {
+		return true
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	backoff := 10 * time.Microsecond
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		time.Sleep(min(backoff, remaining))
+		if c.mu.TryLock() {
+			if c.trackContention {
+				c.recordWait(start)
+			}
+			return true
+		}
+		backoff = min(2*backoff, time.Millisecond)
+	}
+}
+
+func (c *LFUCache[K, V]) recordWait(start time.Time) {
+	c.lockWaits.Add(1)
+	c.lockWaitTime.Add(int64(time.Since(start)))
+}
+
+// ContentionStats returns how many lock acquisitions had to wait and the
+// total time spent waiting. Both are zero unless WithContentionTracking is set.
+func (c *LFUCache[K, V]) ContentionStats() (waits int64, totalWait time.Duration) {
+	return c.lockWaits.Load(), time.Duration(c.lockWaitTime.Load())
+}
@@ -0,0 +1,104 @@
+package lfu
+
+import (
+	"container/list"
+	"reflect"
+	"unsafe"
+)
+
+// approxSize estimates the bytes used by ent: the entry itself, its list
+// node and whatever the key and value reference one level deep.
+func approxSize[K comparable, V any](ent *entry[K, V]) int64 {
+	size := int64(unsafe.Sizeof(*ent)) + int64(unsafe.Sizeof(list.Element{}))
+	return size + referencedSize(ent.key) + referencedSize(ent.value)
+}
+
+// referencedSize returns the bytes v refers to beyond its static size:
+// string contents, slice and map elements, the target of a pointer or the
+// dynamic value of an interface, without following references further.
+func referencedSize[T any](v T) int64 {
+	rv := reflect.ValueOf(&v).Elem()
+	var size int64
+	if rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+		size = int64(rv.Type().Size())
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if !rv.IsNil() {
+			size += int64(rv.Type().Elem().Size())
+		}
+	case reflect.String:
+		size += int64(rv.Len())
+	case reflect.Slice:
+		size += int64(rv.Cap()) * int64(rv.Type().Elem().Size())
+	case reflect.Map:
+		size += int64(rv.Len()) * int64(rv.Type().Key().Size()+rv.Type().Elem().Size())
+	}
+	return size
+}
+
+// weigh records the estimated size of ent, which must be linked into the
+// cache, after its value changed. Must be called with c.mu held.
+func (c *LFUCache[K, V]) weigh(ent *entry[K, V]) {
+	size := approxSize(ent)
+	c.memUsed += size - ent.memSize
+	ent.memSize = size
+}
+
+// fitMemory evicts entries until the estimated memory use is within the
+// limit set by WithApproxMemoryLimit. Must be called with c.mu held.
+func (c *LFUCache[K, V]) fitMemory() {
+	for c.memUsed > c.memLimit && c.evict() {
+	}
+}
+
+// MemoryStats describes the memory the cache structures use, apart from
+// what keys and values reference.
+type MemoryStats struct {
+	Entries   int   // cached entries, including expired ones not yet removed
+	Buckets   int   // distinct frequencies
+	ListNodes int   // list elements, one per entry not in a compact bucket
+	Overhead  int64 // estimated bytes, see LFUCache.MemoryStats
+}
+
+// MemoryStats returns the entry, bucket and list node counts and an
+// estimate of the bytes they take: the entry structs, buckets, list
+// elements and compact bucket slots at their static sizes, plus both
+// maps. Each map entry is counted as its key, a pointer and a control
+// byte, scaled up by 8/7 for the free slots Go keeps in a full map. Maps
+// grown larger in the past and allocator rounding are not accounted for,
+// see ShrinkToFit.
+func (c *LFUCache[K, V]) MemoryStats() MemoryStats {
+	c.rlock()
+	defer c.mu.RUnlock()
+	stats := MemoryStats{Entries: c.size, Buckets: len(c.freqMap)}
+	var lists, slots int
+	for _, bucket := range c.freqMap {
+		if bucket.items != nil {
+			lists++
+			stats.ListNodes += bucket.len()
+		} else {
+			slots += cap(bucket.slots)
+		}
+	}
+
+	var (
+		ent   entry[K, V]
+		key   K
+		node  list.Element
+		ptr   uintptr
+		flist freqList[K, V]
+	)
+	keyMapEntry := (int64(unsafe.Sizeof(key)) + int64(unsafe.Sizeof(ptr)) + 1) * 8 / 7
+	freqMapEntry := (int64(unsafe.Sizeof(0)) + int64(unsafe.Sizeof(ptr)) + 1) * 8 / 7
+	stats.Overhead = int64(c.size)*(int64(unsafe.Sizeof(ent))+keyMapEntry) +
+		int64(stats.ListNodes)*int64(unsafe.Sizeof(node)) +
+		int64(slots)*int64(unsafe.Sizeof(ptr)) +
+		int64(lists)*int64(unsafe.Sizeof(list.List{})) +
+		int64(stats.Buckets)*(int64(unsafe.Sizeof(flist))+freqMapEntry)
+	return stats
+}
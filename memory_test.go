@@ -0,0 +1,106 @@
+package lfu
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test WithApproxMemoryLimit evicts once the estimate exceeds the limit
+func TestApproxMemoryLimit(t *testing.T) {
+	per := approxSize(&entry[int, [64]byte]{})
+	cache := newTestCache[int, [64]byte](100, time.Minute, nil,
+		WithApproxMemoryLimit[int, [64]byte](10*per+per/2))
+
+	for i := 0; i < 10; i++ {
+		cache.Set(i, [64]byte{})
+		cache.Get(i)
+	}
+	if n := cache.Len(); n != 10 {
+		t.Errorf("Expected 10 entries within the limit, got %d", n)
+	}
+	cache.Set(10, [64]byte{})
+	if n := cache.Len(); n != 10 {
+		t.Errorf("Expected the limit to hold 10 entries, got %d", n)
+	}
+	if _, ok := cache.Get(10); ok {
+		t.Errorf("Expected the least frequently used key to be evicted")
+	}
+}
+
+// Test growing a value counts against the limit
+func TestApproxMemoryLimitGrowth(t *testing.T) {
+	per := approxSize(&entry[string, string]{key: "a"})
+	cache := newTestCache[string, string](100, time.Minute, nil,
+		WithApproxMemoryLimit[string, string](2*per+100))
+
+	cache.Set("a", "")
+	cache.Set("b", "")
+	for i := 0; i < 3; i++ {
+		cache.Get("b")
+	}
+	cache.Set("a", strings.Repeat("x", 50))
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected both entries to fit, got %d", n)
+	}
+	cache.Set("a", strings.Repeat("x", 200))
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected the grown entry to be evicted")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("Expected b to survive")
+	}
+}
+
+// Test MemoryStats counts entries, buckets and list nodes
+func TestMemoryStats(t *testing.T) {
+	cache := newTestCache[int, int](10, time.Minute, nil, WithCompactLowFrequency[int, int](true))
+	for i := 0; i < 6; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(0)
+	cache.Get(1)
+	cache.Get(1)
+
+	stats := cache.MemoryStats()
+	if stats.Entries != 6 || stats.Buckets != 3 || stats.ListNodes != 2 {
+		t.Errorf("Expected 6 entries, 3 buckets and 2 list nodes, got %+v", stats)
+	}
+	if stats.Overhead <= 0 {
+		t.Errorf("Expected a positive overhead, got %d", stats.Overhead)
+	}
+
+	for i := 6; i < 10; i++ {
+		cache.Set(i, i)
+	}
+	if grown := cache.MemoryStats(); grown.Entries != 10 || grown.Overhead <= stats.Overhead {
+		t.Errorf("Expected the overhead to grow with the entries, got %+v after %+v", grown, stats)
+	}
+}
+
+// Test entries too large for the limit are evicted at once without
+// being touched afterwards
+func TestApproxMemoryLimitOversized(t *testing.T) {
+	feed := make(chan ChangeEvent[string, string], 16)
+	cache := newTestCache(4, time.Minute, nil,
+		WithApproxMemoryLimit[string, string](300),
+		WithVictimCache[string, string](4),
+		WithChangeFeed(feed))
+
+	big := strings.Repeat("x", 400)
+	cache.Set("a", big) // evicted into the victim cache at once
+	if v, ok := cache.Get("a"); !ok || v != big {
+		t.Errorf("Expected the victim cache to hand back a, got %v", ok)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected a to stay out of the cache, got %d entries", cache.Len())
+	}
+	for len(feed) > 0 {
+		if ev := <-feed; ev.Op == OpSet {
+			t.Errorf("Expected no set event for the evicted a")
+		}
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected consistent structures, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package lfu
+
+import "time"
+
+// Merge copies other's live entries into c. When a key exists in both,
+// onConflict picks the value from the existing and incoming ones, or the
+// incoming one wins if onConflict is nil. The picked value is stored like
+// with Set, through the writer and restarting the TTL, and the frequencies
+// are summed. Other keys keep their frequency and creation time from
+// other. If the result exceeds the capacity, the least frequently used
+// entries are evicted as usual, so higher-frequency entries win. other is
+// read under its own lock and left unchanged.
+func (c *LFUCache[K, V]) Merge(other *LFUCache[K, V], onConflict func(existing, incoming V) V) {
+	if other == c {
+		return
+	}
+	if onConflict == nil {
+		onConflict = func(_, incoming V) V { return incoming }
+	}
+	other.rlock()
+	incoming := make([]*entry[K, V], 0, other.size)
+	for _, ent := range other.keyMap {
+		if !other.isExpired(ent) {
+			incoming = append(incoming, &entry[K, V]{
+				key:         ent.key,
+				value:       ent.value,
+				frequency:   ent.frequency,
+				createdAt:   ent.createdAt,
+				expiresAt:   ent.expiresAt,
+				decayWeight: ent.decayWeight,
+			})
+		}
+	}
+	other.mu.RUnlock()
+
+	if !c.lockUnsealed() {
+		return
+	}
+	if c.capacity == 0 {
+		c.unlock()
+		return
+	}
+	var conflicts []*entry[K, V]
+	for _, in := range incoming {
+		ent, ok := c.keyMap[in.key]
+		if ok && c.isExpired(ent) {
+			c.deleteKey(ent.key, ent)
+			ok = false
+		}
+		if ok {
+			in.value = onConflict(ent.value, in.value)
+			conflicts = append(conflicts, in)
+			continue
+		}
+		c.beforeSet(in.key)
+		in.seq = c.nextSeq
+		c.nextSeq++
+		in.lastAccess = in.createdAt
+		c.insert(in)
+		if c.keyMap[in.key] == in {
+			c.emit(OpSet, in)
+		}
+	}
+	c.drainPending()
+	for c.size > c.highWater && c.evict() {
+	}
+	c.unlock()
+
+	for _, in := range conflicts {
+		_ = c.writeLocked(in.key, in.value, func() { c.mergeEntry(in) })
+	}
+}
+
+// mergeEntry stores in, an entry from another cache, summing its
+// frequency with that of the entry it replaces. Must be called with c.mu
+// held.
+func (c *LFUCache[K, V]) mergeEntry(in *entry[K, V]) {
+	ent, ok := c.keyMap[in.key]
+	if !ok {
+		c.set(in.key, in.value) // removed since the conflict was resolved
+		return
+	}
+	c.beforeSet(in.key)
+	c.unlink(ent)
+	ent.value = in.value
+	ent.frequency += in.frequency
+	ent.createdAt = c.clock()
+	ent.expiresAt = time.Time{}
+	c.insert(ent)
+	if c.keyMap[ent.key] == ent {
+		c.emit(OpSet, ent)
+	}
+}
@@ -0,0 +1,75 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test Merge folds in new keys and resolves conflicts
+func TestMerge(t *testing.T) {
+	dst := newTestCache[string, int](10, time.Minute, nil)
+	src := newTestCache[string, int](10, time.Minute, nil)
+	dst.Set("a", 1)
+	dst.Set("shared", 10)
+	src.Set("b", 2)
+	src.Set("shared", 5)
+	src.Get("shared")
+
+	dst.Merge(src, func(existing, incoming int) int { return existing + incoming })
+
+	if v, _ := dst.Peek("shared"); v != 15 {
+		t.Errorf("Expected the conflict to resolve to 15, got %d", v)
+	}
+	if f := frequencyOf(dst, "shared"); f != 3 {
+		t.Errorf("Expected summed frequency 3, got %d", f)
+	}
+	if v, ok := dst.Peek("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 merged in, got %v", v)
+	}
+	if src.Len() != 2 {
+		t.Errorf("Expected the source to be left unchanged")
+	}
+}
+
+// Test Merge keeps the most frequently used entries within capacity
+func TestMergeOverCapacity(t *testing.T) {
+	dst := newTestCache[string, int](2, time.Minute, nil)
+	src := newTestCache[string, int](2, time.Minute, nil)
+	dst.Set("cold", 1)
+	dst.Set("warm", 2)
+	dst.Get("warm")
+	src.Set("hot", 3)
+	src.Get("hot")
+	src.Get("hot")
+
+	dst.Merge(src, func(existing, incoming int) int { return incoming })
+
+	if dst.Len() != 2 || dst.Contains("cold") {
+		t.Errorf("Expected cold to be evicted, got %v", dst.Keys())
+	}
+	if err := dst.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
+
+// Test conflicts are stored like a Set: written through, invalidating dependents
+func TestMergeConflictWrite(t *testing.T) {
+	w := &flakyWriter{}
+	dst := newTestCache[string, int](10, time.Minute, nil, WithWriter(w.write))
+	src := newTestCache[string, int](10, time.Minute, nil)
+	dst.Set("a", 1)
+	dst.SetWithDependencies("derived", 10, "a")
+	src.Set("a", 2)
+	w.calls, w.stored = 0, nil
+
+	dst.Merge(src, nil) // the incoming value wins
+	if v, _ := dst.Peek("a"); v != 2 {
+		t.Errorf("Expected the incoming a=2 to win, got %d", v)
+	}
+	if w.calls != 1 || w.stored["a"] != 2 {
+		t.Errorf("Expected the resolved a=2 to be written through, got %v", w.stored)
+	}
+	if dst.Contains("derived") {
+		t.Errorf("Expected the entry derived from a to be invalidated")
+	}
+}
@@ -0,0 +1,612 @@
+package lfu
+
+import "time"
+
+// Option configures optional behaviour of an LFU cache.
+type Option[K comparable, V any] func(*LFUCache[K, V])
+
+// Serve entries for up to staleWindow past their TTL while loader refreshes
+// them in the background. Refreshes are deduplicated per key.
+func WithStaleWhileRevalidate[K comparable, V any](
+	staleWindow time.Duration,
+	loader func(K) (V, error),
+) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.staleWindow = staleWindow
+		c.revalidate = loader
+	}
+}
+
+// Start evicting once the cache holds n entries. Defaults to the capacity and
+// is clamped to it.
+func WithHighWaterMark[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.highWater = n
+	}
+}
+
+// Evict down to n entries whenever the high-water mark is reached, instead of
+// evicting a single entry. Defaults to one below the high-water mark. The
+// cache then fills to roughly (low+high)/2 entries on average.
+func WithLowWaterMark[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.lowWater = n
+	}
+}
+
+// Use codec to serialize entries in Save and Load instead of gob.
+func WithCodec[K comparable, V any](codec Codec[K, V]) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.codec = codec
+		if codec == nil {
+			c.nilCallbacks = append(c.nilCallbacks, "WithCodec")
+		}
+	}
+}
+
+// Defer frequency increments on Get so hits only take the read lock. Bumps
+// are buffered and applied in batches by the cleanup loop, before evictions,
+// or when the buffer fills, so frequencies may briefly lag behind reads.
+// Under heavy write contention some bumps may be dropped.
+func WithDeferredIncrements[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		if enabled {
+			c.pending = make(chan *entry[K, V], pendingIncrements)
+		} else {
+			c.pending = nil
+		}
+	}
+}
+
+// Choose how the victim is picked among entries with the lowest frequency.
+func WithTiebreak[K comparable, V any](policy TiebreakPolicy) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.tiebreak = policy
+	}
+}
+
+// Collapse concurrent Sets of the same key into a single update. Sets that
+// arrive while another Set of the key waits for the lock replace its value
+// and return once it has been applied, so the key's frequency is bumped once.
+func WithSetCoalescing[K comparable, V any]() Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.inflightSets = make(map[K]*pendingSet[V])
+	}
+}
+
+// Load missing keys through loader on Get and GetE, caching the result.
+// Concurrent misses on the same key share one loader call.
+func WithLoader[K comparable, V any](loader func(K) (V, error)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.loader = loader
+		if loader == nil {
+			c.nilCallbacks = append(c.nilCallbacks, "WithLoader")
+		}
+	}
+}
+
+// Report how long each Get ("get") and Set ("set") took, including time
+// spent waiting for the lock. Loader calls are not included. The observer
+// is called after the lock is released.
+func WithTimingObserver[K comparable, V any](observe func(op string, d time.Duration)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.observeTiming = observe
+	}
+}
+
+// Periodically reduce every entry's frequency by factor (0..1) so that
+// formerly popular keys can eventually be evicted. Entries never decay
+// below a frequency of 1. After each pass the remaining frequencies are
+// renumbered to 1..k in order, as by NormalizeFrequencies, so the buckets
+// stay dense. See SetWithDecayWeight for per-key rates.
+func WithFrequencyDecay[K comparable, V any](interval time.Duration, factor float64) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.decayInterval = interval
+		c.decayFactor = factor
+	}
+}
+
+// Preallocate room for n keys to avoid rehashing while the cache warms up.
+// Defaults to the capacity.
+func WithInitialCapacityHint[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.sizeHint = n
+	}
+}
+
+// Publish every mutation (sets, deletes, evictions and expirations) on ch
+// so peers can mirror the cache. Sends never block: when ch is full the
+// event is dropped and counted in DroppedChanges, and the consumer should
+// resynchronize.
+func WithChangeFeed[K comparable, V any](ch chan<- ChangeEvent[K, V]) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.changes = ch
+	}
+}
+
+// Reject operations once Stop has been called: Get misses, Set is dropped,
+// and GetE and SetWithError return ErrCacheStopped.
+func WithStrictStop[K comparable, V any]() Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.strictStop = true
+	}
+}
+
+// Approximate LFU eviction by sampling k entries and evicting the least
+// frequently used among them, instead of always taking the exact minimum.
+// The tiebreak policy does not apply in this mode.
+func WithSampledEviction[K comparable, V any](k int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.evictSamples = k
+	}
+}
+
+// Run eviction callbacks on a pool of workers so slow callbacks don't hold
+// up the operations that trigger them. Callbacks may run in any order, even
+// for the same key. Stop waits for queued callbacks to finish.
+func WithAsyncCallbacks[K comparable, V any](workers int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.callbackWorkers = workers
+	}
+}
+
+// Skip entries created or updated less than d ago when picking eviction
+// victims, giving new keys a chance to be read. If every entry is
+// protected, the oldest one is evicted.
+func WithProtectNewFor[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.protectNew = d
+	}
+}
+
+// Call listener, in addition to the eviction callback, whenever an entry is
+// evicted, expires or is dropped by ReplaceAll, along with the reason.
+func WithEvictionListener[K comparable, V any](listener func(key K, value V, reason EvictionReason)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.evictListener = listener
+	}
+}
+
+// Write every Set through to a backing store before caching it. If writer
+// fails the value is not cached and SetWithError returns the error.
+func WithWriter[K comparable, V any](writer func(K, V) error) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.writer = writer
+		if writer == nil {
+			c.nilCallbacks = append(c.nilCallbacks, "WithWriter")
+		}
+	}
+}
+
+// Retry failed writes up to maxAttempts times in total, waiting backoff
+// before the first retry and doubling the wait after each one.
+func WithWriteRetry[K comparable, V any](maxAttempts int, backoff time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.writeRetries = maxAttempts
+		c.writeBackoff = backoff
+	}
+}
+
+// Call handler with writes that still failed after all retries.
+func WithWriteErrorHandler[K comparable, V any](handler func(key K, value V, err error)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.onWriteError = handler
+	}
+}
+
+// Buffer Sets and hand them to flush in batches of up to batchSize, every
+// flushInterval or as soon as batchSize distinct keys are dirty. Multiple
+// updates of a key between flushes are coalesced into the latest value.
+// Stop flushes whatever is still buffered, and Sets after Stop are flushed
+// one by one as they happen. Failed flushes are retried per WithWriteRetry
+// and then reported to the write error handler.
+func WithWriteBehind[K comparable, V any](
+	flushInterval time.Duration,
+	batchSize int,
+	flush func([]KeyValue[K, V]) error,
+) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.flushInterval = flushInterval
+		c.flushBatch = max(batchSize, 1)
+		c.flushBehind = flush
+		if flush == nil {
+			c.nilCallbacks = append(c.nilCallbacks, "WithWriteBehind")
+		}
+	}
+}
+
+// Treat Sets of values for which isEmpty returns true as deletes.
+func WithDeleteOnZero[K comparable, V any](isEmpty func(V) bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.deleteOnZero = isEmpty
+	}
+}
+
+// Record how often and how long operations wait for the cache lock,
+// reported by ContentionStats.
+func WithContentionTracking[K comparable, V any]() Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.trackContention = true
+	}
+}
+
+// Read the current time from now instead of time.Now. Entry ages are
+// computed as now().Sub(createdAt), so a clock whose readings carry a
+// monotonic component, like time.Now, keeps expiry immune to wall-clock
+// jumps; a clock without one makes expiry follow its wall time.
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.clock = now
+		if now == nil {
+			c.nilCallbacks = append(c.nilCallbacks, "WithClock")
+		}
+	}
+}
+
+// Call fn with the fill ratio (size/capacity) when a Set brings it to
+// threshold or above. The alert fires once per crossing: it is re-armed
+// only after a later Set finds the ratio below threshold again. fn runs
+// after the lock is released.
+func WithUtilizationAlert[K comparable, V any](threshold float64, fn func(util float64)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.utilThreshold = threshold
+		c.utilAlert = fn
+	}
+}
+
+// Keep at most n frequency buckets, bounding their memory on long-running
+// workloads. When a new bucket would exceed the limit, the two buckets with
+// the closest frequencies are merged into the higher one, at the cost of
+// some frequency resolution.
+func WithMaxBuckets[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.maxBuckets = max(n, 1)
+	}
+}
+
+// Count at most one access per entry within each window, so a key read in
+// a tight loop gains frequency by rate rather than by volume. Accesses
+// inside the window still refresh the entry's recency.
+func WithFrequencyDebounce[K comparable, V any](window time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.freqDebounce = window
+	}
+}
+
+// Call observe whenever the lowest frequency in the cache changes, with 0
+// standing for an empty cache. It reports the net change of each write
+// operation, after the lock is released. Expect it to fire on most Sets
+// and Gets when the cache churns, so keep it cheap.
+func WithMinFreqObserver[K comparable, V any](observe func(old, new int)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.onMinFreq = observe
+	}
+}
+
+// Pass each looked-up key to sink with probability rate (0..1), hits and
+// misses alike, to capture a sample of the workload. sink runs on the
+// calling goroutine before the lookup, so it must be fast and must not
+// call back into the cache.
+func WithAccessSampler[K comparable, V any](rate float64, sink func(K)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.sampleRate = rate
+		c.sampleSink = sink
+	}
+}
+
+// Keep the last size entries evicted for capacity in a small LRU buffer. A
+// Get that misses the cache but finds the key there promotes the entry
+// back with its frequency, instead of missing. Expired entries are not
+// promoted.
+func WithVictimCache[K comparable, V any](size int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		if size > 0 {
+			c.victims = newVictimCache[K, V](size)
+		}
+	}
+}
+
+// Call observe with the age of each entry evicted for capacity, that is
+// how long ago it was created or last set. Many young evictions suggest
+// the cache is too small. It runs with the other eviction callbacks,
+// outside the lock.
+func WithEvictionAgeObserver[K comparable, V any](observe func(age time.Duration)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.observeEvictAge = observe
+	}
+}
+
+// Close values that implement io.Closer once they leave the cache through
+// eviction, expiry, Delete, InvalidateTag or ReplaceAll, after the lock is
+// released. Close errors are ignored. Values overwritten by a Set and
+// values returned by Take are left open, since the caller may still hold
+// them. With WithVictimCache, evicted values are closed when they leave
+// the victim cache.
+func WithAutoClose[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.autoClose = enabled
+	}
+}
+
+// Reap at most n expired entries per cleanup tick, leaving the rest for
+// later ticks, to bound how long the loop holds the lock. Expired entries
+// are still never served in the meantime.
+func WithMaxCleanupPerTick[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.maxCleanup = max(n, 0)
+	}
+}
+
+// Let Sets of an existing key replace its value and restart its TTL
+// without bumping its frequency, so only reads make a key popular.
+func WithNoFreqBumpOnSet[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.noBumpOnSet = enabled
+	}
+}
+
+// Route keys to shards of a ShardedCache with hash instead of FNV-1a of
+// the key. Plain caches ignore it.
+func WithHasher[K comparable, V any](hash func(K) uint64) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.hasher = hash
+	}
+}
+
+// Stop calling the loader for cooldown after failureThreshold consecutive
+// loader errors, failing loads with ErrCircuitOpen instead. After the
+// cooldown a single trial load is let through: success closes the
+// breaker, failure opens it for another cooldown.
+func WithLoaderCircuitBreaker[K comparable, V any](failureThreshold int, cooldown time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.breakerThreshold = failureThreshold
+		c.breakerCooldown = cooldown
+	}
+}
+
+// Keep entries that have not been accessed since they were inserted in a
+// slice rather than a linked list, saving a list node per entry in
+// workloads dominated by keys that are only ever used once.
+func WithCompactLowFrequency[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.compactLow = enabled
+	}
+}
+
+// Log evictions, expirations and replacements ("entry evicted" with the
+// reason, key, frequency and age) as well as loader and write failures to
+// logger, in the style of slog: a message followed by alternating keys and
+// values. Evictions are logged with the other eviction callbacks, outside
+// the lock.
+func WithLogger[K comparable, V any](logger func(msg string, kv ...any)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.logger = logger
+	}
+}
+
+// Evict the least recently used entries of a frequency bucket as soon as
+// it holds more than n entries, even when the cache is below capacity.
+// The capacity still applies on top, so the cache holds at most n entries
+// per distinct frequency and never more than its capacity.
+func WithMaxBucketSize[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.maxBucketSize = max(n, 1)
+	}
+}
+
+// Run at most n loader calls at a time. Misses on other keys wait for a
+// running load to finish; misses on a key already being loaded share its
+// call as usual, so n bounds the load on the backing store.
+func WithMaxConcurrentLoads[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.loadSlots = make(chan struct{}, max(n, 1))
+	}
+}
+
+// Choose eviction victims with policy, such as NewLRUPolicy or
+// NewFIFOPolicy, instead of NewLFUPolicy. TTLs, stats, callbacks and
+// write-through work as before; frequencies are still counted and options
+// built on them, like WithMaxBucketSize, keep applying. A nil policy keeps
+// the default.
+func WithPolicy[K comparable, V any](policy Policy[K, V]) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.policy = policy
+	}
+}
+
+// Count Gets per key over each interval so that HotKeys can report the
+// keys read disproportionately often. Counting takes a lock of its own
+// on every Get. A non-positive interval means one second.
+func WithHotKeyTracking[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		c.hot = &hotKeyTracker[K]{interval: interval, counts: make(map[K]int)}
+	}
+}
+
+// Evict least frequently used entries while the estimated memory held by
+// the entries exceeds bytes, on top of the capacity. The estimate is
+// approximate: it covers the entry bookkeeping, the static size of keys
+// and values, and what they reference one level deep, such as string
+// contents, slice and map elements or the target of a pointer. Anything
+// referenced further, like the strings in a []string or the fields behind
+// a pointer inside a struct, is not counted, so values built of nested
+// pointers and slices are undercounted. It is most accurate for fixed-size
+// types and strings.
+func WithApproxMemoryLimit[K comparable, V any](bytes int64) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.memLimit = bytes
+	}
+}
+
+// Call observe after each pass of the cleanup loop with how long the pass
+// held the lock and how many expired entries it removed, outside the lock.
+// Passes that keep growing longer suggest a shorter cleanup interval or
+// WithMaxCleanupPerTick.
+func WithCleanupObserver[K comparable, V any](observe func(d time.Duration, reaped int)) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.observeCleanup = observe
+	}
+}
+
+// Drop Sets of new keys on a full cache when every entry that could be
+// evicted for them has been accessed since it was inserted, so a scan of
+// one-off keys can't push out the working set. Dropped inserts are not
+// stored at all and are counted in CacheStats.Rejections; updates of
+// cached keys are never dropped. Since a new key is only admitted over
+// entries used once, pair it with WithFrequencyDecay to let the cache
+// move on to a new working set. Ignored under WithPolicy.
+func WithAdmitOnlyIfColderVictim[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.admitColder = enabled
+	}
+}
+
+// Measure frequency over the last duration only. Accesses are counted in
+// buckets covering duration/buckets each; every such tick the oldest
+// bucket is dropped and each entry's frequency becomes the sum of the
+// remaining ones, but at least 1, so keys that were popular long ago lose
+// priority to recently popular ones. Frequencies changed by other means,
+// such as ImportPriority or WithFrequencyDecay, are overridden on the next
+// tick.
+func WithWindow[K comparable, V any](duration time.Duration, buckets int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.windowBuckets = max(buckets, 1)
+		c.windowTick = duration / time.Duration(c.windowBuckets)
+	}
+}
+
+// Count the TTL from an entry's last access instead of its last Set, so
+// entries expire only after going unused for the TTL. Get and the cleanup
+// loop agree on this: an entry a Get still serves is never reaped. Reads
+// that don't count as accesses, like Peek, don't extend the TTL. Deadlines
+// from SetWithDeadline are not affected.
+func WithSlidingTTL[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.slidingTTL = enabled
+	}
+}
+
+// Call fn when the cache becomes empty, after the lock is released. It
+// fires once per transition, comparing the size at the end of each write
+// operation, so an entry added and removed again within one operation
+// goes unnoticed.
+func WithOnEmpty[K comparable, V any](fn func()) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.onEmpty = fn
+	}
+}
+
+// Call fn when the cache stops being empty, after the lock is released.
+// Like WithOnEmpty, it fires once per transition.
+func WithOnNonEmpty[K comparable, V any](fn func()) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.onNonEmpty = fn
+	}
+}
+
+// Hand out copier(value) instead of the cached value from Get, GetE,
+// GetOrCompute, GetMulti, Peek, Range, Entries and SampleByFrequency, so
+// callers can't modify the cached copy of slices, maps or pointed-to
+// values through what they are given. Values passed to Set are stored as
+// they are. copier runs outside the lock for every value returned.
+func WithValueCopier[K comparable, V any](copier func(V) V) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.copier = copier
+	}
+}
+
+// Split the hit and miss counters into n shards, rounded up to a power of
+// two, that Gets pick at random, so that Gets on many cores don't all
+// contend for one cache line. Stats sums the shards. Only worth it at very
+// high read rates, as it makes each counter n cache lines large.
+func WithShardedCounters[K comparable, V any](n int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.hits.shard(n)
+		c.misses.shard(n)
+	}
+}
+
+// Write entries evicted for capacity to store, and on a Get miss look the
+// key up there, caching a found entry again with a fresh TTL and removing
+// it from the store. Set, Delete and Take drop the stored copy. Store
+// calls run outside the lock and their errors are logged. A Delete racing
+// with the eviction of the same key may leave a stale copy behind.
+func WithSpillover[K comparable, V any](store SpillStore[K, V]) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.spill = store
+	}
+}
+
+// Keep expired entries for up to maxStale past their TTL and, when GetE
+// fails to reload one, return the expired value as found along with an
+// error wrapping ErrStale and the loader error, so Get serves it too.
+// Past maxStale the loader error is returned as usual.
+func WithServeStaleOnError[K comparable, V any](maxStale time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.maxStale = maxStale
+	}
+}
+
+// Send each key removed after its TTL on ch, whether the cleanup loop or
+// a read found it expired. Capacity evictions and deletes are not sent.
+// Sends never block: when ch is full the key is dropped and counted in
+// DroppedExpirations.
+func WithExpirationChannel[K comparable, V any](ch chan<- K) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.expirations = ch
+	}
+}
+
+// Turn off locking, which saves the cost of the mutex when the cache is
+// only ever used from one goroutine at a time, such as a request-scoped
+// cache. WARNING: the caller must serialize every call itself; using the
+// cache from several goroutines at once then corrupts it. Since it would
+// race with the caller, the cleanup loop doesn't run, so expired entries
+// are only removed when read or by DrainExpired, and WithFrequencyDecay
+// and WithWindow never age frequencies. WithStaleWhileRevalidate reloads
+// on the reading goroutine instead of in the background.
+func WithoutLocking[K comparable, V any]() Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.mu = noLock{}
+		c.unlocked = true
+	}
+}
+
+// Hash entries for Checksum with hash instead of their fmt
+// representation, for values that don't print their contents or print
+// them slowly.
+func WithEntryHasher[K comparable, V any](hash func(K, V) uint64) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.entryHasher = hash
+	}
+}
+
+// Demote an entry to frequency demoteTo on its next access once its
+// frequency has reached ceiling, instead of counting the access, so even
+// the hottest keys periodically become eviction candidates again. demoteTo
+// is clamped to between 1 and ceiling-1. Frequencies set by Merge, Apply,
+// ImportPriority or ScaleFrequencies may exceed ceiling until their next
+// access.
+func WithFrequencyCeiling[K comparable, V any](ceiling, demoteTo int) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.freqCeiling = ceiling
+		c.demoteTo = max(min(demoteTo, ceiling-1), 1)
+	}
+}
+
+// Fail GetE and GetOrCompute with ErrComputeTimeout once the load or
+// compute they wait on has run for d, so a hung loader can't block its
+// callers forever. Callers that miss the key later while it is still
+// running fail at once instead of starting another call. The timed-out
+// call keeps running in the background and its result is cached if it
+// eventually succeeds. GetMulti's own batchLoader call is not timed out,
+// only its waits on loads started by others. Under WithoutLocking loads
+// run on the caller and are not timed out.
+func WithComputeTimeout[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *LFUCache[K, V]) {
+		c.computeTimeout = d
+	}
+}
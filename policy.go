@@ -0,0 +1,121 @@
+package lfu
+
+import "container/list"
+
+// Policy chooses eviction victims. The default, NewLFUPolicy, evicts by
+// frequency. The cache calls it with its lock held, so implementations
+// need no locking of their own but must not call back into the cache, and
+// a policy must not be shared between caches.
+type Policy[K comparable, V any] interface {
+	// Add records a key that was inserted into the cache.
+	Add(key K, value V)
+	// Access records a counted read or update of key.
+	Access(key K)
+	// Remove forgets key. It may be called for keys the policy doesn't hold.
+	Remove(key K)
+	// Evict returns the next victim, or false when the policy holds no keys.
+	Evict() (K, bool)
+}
+
+// lfuPolicy evicts from the frequency buckets its cache keeps for every
+// policy, so it has nothing to record itself.
+type lfuPolicy[K comparable, V any] struct {
+	c *LFUCache[K, V]
+}
+
+// NewLFUPolicy returns the default Policy, which evicts the least
+// frequently used key, picking among equally frequent keys by the
+// TiebreakPolicy, or approximately under WithSampledEviction. Unlike
+// other policies it may be passed to several caches.
+func NewLFUPolicy[K comparable, V any]() Policy[K, V] {
+	return &lfuPolicy[K, V]{}
+}
+
+func (p *lfuPolicy[K, V]) Add(K, V) {}
+func (p *lfuPolicy[K, V]) Access(K) {}
+func (p *lfuPolicy[K, V]) Remove(K) {}
+
+func (p *lfuPolicy[K, V]) Evict() (K, bool) {
+	var victim *entry[K, V]
+	if p.c.evictSamples > 0 {
+		victim = p.c.sampleVictim()
+	} else {
+		victim = p.c.exactVictim()
+	}
+	if victim == nil {
+		var zero K
+		return zero, false
+	}
+	return victim.key, true
+}
+
+// evictsByFrequency reports whether the cache uses the LFU policy.
+func (c *LFUCache[K, V]) evictsByFrequency() bool {
+	_, ok := c.policy.(*lfuPolicy[K, V])
+	return ok
+}
+
+// listPolicy keeps keys in a list ordered by insertion, optionally moving
+// them to the front when accessed.
+type listPolicy[K comparable, V any] struct {
+	order        *list.List // of K, newest first
+	elems        map[K]*list.Element
+	moveOnAccess bool
+}
+
+// NewLRUPolicy returns a Policy that evicts the least recently used key.
+func NewLRUPolicy[K comparable, V any]() Policy[K, V] {
+	return &listPolicy[K, V]{order: list.New(), elems: make(map[K]*list.Element), moveOnAccess: true}
+}
+
+// NewFIFOPolicy returns a Policy that evicts the key inserted first,
+// regardless of how it is accessed.
+func NewFIFOPolicy[K comparable, V any]() Policy[K, V] {
+	return &listPolicy[K, V]{order: list.New(), elems: make(map[K]*list.Element)}
+}
+
+func (p *listPolicy[K, V]) Add(key K, _ V) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *listPolicy[K, V]) Access(key K) {
+	if e, ok := p.elems[key]; ok && p.moveOnAccess {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *listPolicy[K, V]) Remove(key K) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *listPolicy[K, V]) Evict() (K, bool) {
+	e := p.order.Back()
+	if e == nil {
+		var zero K
+		return zero, false
+	}
+	key := p.order.Remove(e).(K)
+	delete(p.elems, key)
+	return key, true
+}
+
+// policyVictim returns the entry chosen by the configured policy, skipping
+// keys the policy holds but the cache no longer does.
+func (c *LFUCache[K, V]) policyVictim() *entry[K, V] {
+	for {
+		key, ok := c.policy.Evict()
+		if !ok {
+			return nil
+		}
+		if ent, ok := c.keyMap[key]; ok {
+			return ent
+		}
+	}
+}
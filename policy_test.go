@@ -0,0 +1,97 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the LRU policy evicts by recency rather than frequency
+func TestLRUPolicy(t *testing.T) {
+	var evicted []string
+	cache := newTestCache[string, int](2, time.Minute, func(key string, _ int) {
+		evicted = append(evicted, key)
+	}, WithPolicy(NewLRUPolicy[string, int]()))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected the least recently used key a to be evicted")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("Expected b to survive")
+	}
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("Expected the eviction callback for a, got %v", evicted)
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+}
+
+// Test the LFU policy is the default and can be shared between caches
+func TestLFUPolicy(t *testing.T) {
+	if cache := newTestCache[string, int](2, time.Minute, nil); !cache.evictsByFrequency() {
+		t.Errorf("Expected the LFU policy by default")
+	}
+
+	lfu := NewLFUPolicy[string, int]()
+	a := newTestCache(2, time.Minute, nil, WithPolicy(lfu))
+	b := newTestCache(2, time.Minute, nil, WithPolicy(lfu))
+	for _, cache := range []*LFUCache[string, int]{a, b} {
+		cache.Set("x", 1)
+		cache.Get("x")
+		cache.Set("y", 2)
+	}
+	a.Set("z", 3)
+	b.Set("z", 3)
+	for name, cache := range map[string]*LFUCache[string, int]{"a": a, "b": b} {
+		if cache.Contains("y") || !cache.Contains("x") {
+			t.Errorf("Expected %s to evict its least frequently used key y", name)
+		}
+	}
+}
+
+// Test the FIFO policy ignores accesses and deleted keys
+func TestFIFOPolicy(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithPolicy(NewFIFOPolicy[string, int]()))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected the oldest key a to be evicted")
+	}
+
+	cache.Delete("b")
+	cache.Set("d", 4)
+	cache.Set("e", 5)
+	if _, ok := cache.Get("c"); ok {
+		t.Errorf("Expected c to be evicted after b was deleted")
+	}
+	for _, key := range []string{"d", "e"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Expected %s to survive", key)
+		}
+	}
+}
+
+// Test ReplaceAll hands the new keys to the policy
+func TestPolicyReplaceAll(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithPolicy(NewFIFOPolicy[string, int]()))
+	cache.Set("a", 1)
+	cache.ReplaceAll(map[string]int{"b": 2})
+	cache.Set("c", 3)
+	cache.Set("d", 4)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted first")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package lfu
+
+// ReadOnlyCache is a view of a cache that can be read but not modified.
+// None of its methods update frequencies or hit/miss counters.
+type ReadOnlyCache[K comparable, V any] interface {
+	Peek(key K) (V, bool)
+	Contains(key K) bool
+	Keys() []K
+	Len() int
+	Stats() CacheStats
+	Range(fn func(key K, value V) bool)
+}
+
+// ReadOnly returns a read-only view of the cache for code that should only
+// consume it.
+func (c *LFUCache[K, V]) ReadOnly() ReadOnlyCache[K, V] {
+	return readOnly[K, V]{c: c}
+}
+
+// readOnly hides the mutating methods of the wrapped cache.
+type readOnly[K comparable, V any] struct {
+	c *LFUCache[K, V]
+}
+
+func (r readOnly[K, V]) Peek(key K) (V, bool)               { return r.c.Peek(key) }
+func (r readOnly[K, V]) Contains(key K) bool                { return r.c.Contains(key) }
+func (r readOnly[K, V]) Keys() []K                          { return r.c.Keys() }
+func (r readOnly[K, V]) Len() int                           { return r.c.Len() }
+func (r readOnly[K, V]) Stats() CacheStats                  { return r.c.Stats() }
+func (r readOnly[K, V]) Range(fn func(key K, value V) bool) { r.c.Range(fn) }
+
+// Peek returns the value for key without updating its frequency or the
+// hit/miss counters.
+func (c *LFUCache[K, V]) Peek(key K) (V, bool) {
+	c.rlock()
+	ent, ok := c.keyMap[key]
+	var value V
+	if ok && !c.isExpired(ent) {
+		value = ent.value
+	} else {
+		ok = false
+	}
+	c.mu.RUnlock()
+	if !ok {
+		return value, false
+	}
+	return c.copyOut(value), true
+}
+
+// Contains reports whether key holds a live entry, without updating its
+// frequency.
+func (c *LFUCache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Keys returns the live keys in no particular order.
+func (c *LFUCache[K, V]) Keys() []K {
+	c.rlock()
+	defer c.mu.RUnlock()
+	keys := make([]K, 0, c.size)
+	for key, ent := range c.keyMap {
+		if !c.isExpired(ent) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Range calls fn for each live entry, coldest first, until fn returns
+// false. It works on a snapshot, so fn may use the cache.
+func (c *LFUCache[K, V]) Range(fn func(key K, value V) bool) {
+	for _, it := range c.snapshot() {
+		if !fn(it.Key, c.copyOut(it.Value)) {
+			return
+		}
+	}
+}
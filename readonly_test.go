@@ -0,0 +1,44 @@
+package lfu
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// Test the read-only view reads without bumping frequencies or counters
+func TestReadOnly(t *testing.T) {
+	cache := newTestCache[string, int](3, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	view := cache.ReadOnly()
+
+	if v, ok := view.Peek("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1, got %v", v)
+	}
+	if !view.Contains("b") || view.Contains("z") {
+		t.Errorf("Expected Contains to report only b")
+	}
+	keys := view.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Expected keys [a b], got %v", keys)
+	}
+	sum := 0
+	view.Range(func(k string, v int) bool {
+		sum += v
+		return true
+	})
+	if sum != 3 {
+		t.Errorf("Expected Range to visit both entries, got sum %d", sum)
+	}
+	if f := frequencyOf(cache, "a"); f != 1 {
+		t.Errorf("Expected frequency 1, got %d", f)
+	}
+	if s := view.Stats(); s.Hits != 0 || s.Misses != 0 {
+		t.Errorf("Expected no hits or misses, got %+v", s)
+	}
+	if _, ok := view.(interface{ Set(string, int) }); ok {
+		t.Errorf("Expected the view not to expose Set")
+	}
+}
@@ -0,0 +1,97 @@
+package lfu
+
+import "sort"
+
+// ReplaceAll swaps the whole cache contents for items under a single brief
+// write lock, so readers never see a partially populated cache. Keys that
+// were already cached keep their frequency; new keys start at 1. If items
+// exceeds the capacity, the most frequently used keys are kept.
+//
+// Old entries that are not in items are reported to the eviction listener
+// with ReasonReplaced and to the eviction callback, but are not counted as
+// evictions. All tags are cleared.
+func (c *LFUCache[K, V]) ReplaceAll(items map[K]V) {
+	// Read current frequencies, then build the new structures off-lock
+	c.rlock()
+	capacity := c.capacity // may be raised by ReserveTransient
+	freqs := make(map[K]int, len(items))
+	for key := range items {
+		if ent, ok := c.keyMap[key]; ok {
+			freqs[key] = ent.frequency
+		}
+	}
+	c.mu.RUnlock()
+
+	ents := make([]*entry[K, V], 0, len(items))
+	now := c.clock()
+	for key, value := range items {
+		ents = append(ents, &entry[K, V]{
+			key:         key,
+			value:       value,
+			frequency:   max(freqs[key], 1),
+			createdAt:   now,
+			decayWeight: 1,
+		})
+	}
+	if len(ents) > capacity {
+		sort.Slice(ents, func(i, j int) bool {
+			return ents[i].frequency > ents[j].frequency
+		})
+		ents = ents[:max(capacity, 0)]
+	}
+
+	keyMap := make(map[K]*entry[K, V], max(len(ents), c.sizeHint))
+	freqMap := make(map[int]*freqList[K, V])
+	minFreq := 0
+	for _, ent := range ents {
+		keyMap[ent.key] = ent
+		if freqMap[ent.frequency] == nil {
+			freqMap[ent.frequency] = c.newBucket(ent.frequency)
+		}
+		freqMap[ent.frequency].pushFront(ent)
+		if minFreq == 0 || ent.frequency < minFreq {
+			minFreq = ent.frequency
+		}
+	}
+
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+	old := c.keyMap
+	c.keyMap, c.freqMap, c.minFreq, c.size = keyMap, freqMap, minFreq, len(ents)
+	c.tags, c.dependents = nil, nil
+	if c.victims != nil {
+		for _, ent := range c.victims.clear() {
+			c.queueClose(ent.value)
+		}
+	}
+	for key := range c.spilled {
+		c.unspill(key)
+	}
+	for key := range old {
+		c.policy.Remove(key)
+	}
+	for _, ent := range ents {
+		ent.seq = c.nextSeq
+		c.nextSeq++
+		c.policy.Add(ent.key, ent.value)
+		c.emit(OpSet, ent)
+	}
+	if c.memLimit > 0 {
+		c.memUsed = 0
+		for _, ent := range ents {
+			ent.memSize = 0
+			c.weigh(ent)
+		}
+	}
+	for key, ent := range old {
+		if _, ok := keyMap[key]; !ok {
+			c.emit(OpDelete, ent)
+			c.queueEvicted(ent, ReasonReplaced)
+		}
+	}
+	if c.memLimit > 0 {
+		c.fitMemory()
+	}
+}
@@ -0,0 +1,56 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test ReplaceAll swaps contents and reports dropped entries
+func TestReplaceAll(t *testing.T) {
+	reasons := make(map[string]EvictionReason)
+	cache := newTestCache[string, int](3, time.Minute, nil,
+		WithEvictionListener(func(k string, v int, reason EvictionReason) {
+			reasons[k] = reason
+		}))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+
+	cache.ReplaceAll(map[string]int{"b": 20, "c": 30})
+
+	if cache.Len() != 2 {
+		t.Errorf("Expected length 2, got %d", cache.Len())
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to be dropped")
+	}
+	if v, ok := cache.Get("c"); !ok || v != 30 {
+		t.Errorf("Expected c=30, got %v", v)
+	}
+	if f := frequencyOf(cache, "b"); f != 2 {
+		t.Errorf("Expected b to keep frequency 2, got %d", f)
+	}
+	if len(reasons) != 1 || reasons["a"] != ReasonReplaced {
+		t.Errorf("Expected only a reported as replaced, got %v", reasons)
+	}
+	if n := cache.Stats().Evictions; n != 0 {
+		t.Errorf("Expected no evictions counted, got %d", n)
+	}
+}
+
+// Test ReplaceAll keeps the hottest keys when items exceed capacity
+func TestReplaceAllOverCapacity(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("hot", 1)
+	cache.Get("hot")
+
+	cache.ReplaceAll(map[string]int{"hot": 1, "x": 2, "y": 3, "z": 4})
+
+	if cache.Len() != 2 {
+		t.Errorf("Expected length 2, got %d", cache.Len())
+	}
+	if _, ok := cache.Get("hot"); !ok {
+		t.Errorf("Expected hot key to be kept")
+	}
+}
@@ -0,0 +1,139 @@
+package lfu
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ChangeOp identifies the kind of mutation a ChangeEvent describes.
+type ChangeOp int
+
+const (
+	OpSet    ChangeOp = iota // key inserted or updated
+	OpDelete                 // key removed by Delete or Take
+	OpEvict                  // key evicted to make room
+	OpExpire                 // key removed after its TTL
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpEvict:
+		return "evict"
+	case OpExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// ChangeEvent describes a single mutation published on the change feed.
+type ChangeEvent[K comparable, V any] struct {
+	Op        ChangeOp
+	Key       K
+	Value     V
+	Frequency int       // frequency after the mutation
+	CreatedAt time.Time // creation time the entry's TTL counts from
+	ExpiresAt time.Time // deadline replacing the TTL, if not zero
+}
+
+// DroppedChanges returns how many change events were dropped because the
+// change feed channel was full.
+func (c *LFUCache[K, V]) DroppedChanges() int64 {
+	return c.droppedChanges.Load()
+}
+
+// emit publishes a mutation of ent without blocking. Called with c.mu held.
+func (c *LFUCache[K, V]) emit(op ChangeOp, ent *entry[K, V]) {
+	if c.changes == nil {
+		return
+	}
+	event := ChangeEvent[K, V]{
+		Op:        op,
+		Key:       ent.key,
+		Value:     ent.value,
+		Frequency: ent.frequency,
+		CreatedAt: ent.createdAt,
+		ExpiresAt: ent.expiresAt,
+	}
+	select {
+	case c.changes <- event:
+	default:
+		c.droppedChanges.Add(1)
+	}
+}
+
+// Apply replays an event from another cache's change feed. Sets keep the
+// event's frequency, creation time and deadline; the other operations
+// remove the key.
+// The replayed operation is not counted in the stats, runs no callbacks and
+// is not re-published on this cache's change feed. Evictions needed to make
+// room for a Set behave as usual.
+func (c *LFUCache[K, V]) Apply(event ChangeEvent[K, V]) {
+	if !c.lockUnsealed() {
+		return
+	}
+	defer c.unlock()
+
+	ent, ok := c.keyMap[event.Key]
+	if event.Op != OpSet {
+		c.dropVictim(event.Key)
+		if ok {
+			c.unlink(ent)
+			c.queueClose(ent.value)
+		}
+		return
+	}
+	if c.capacity == 0 {
+		return
+	}
+
+	if ok {
+		c.unlink(ent)
+	} else {
+		c.makeRoom()
+		ent = &entry[K, V]{key: event.Key, seq: c.nextSeq, decayWeight: 1}
+		c.nextSeq++
+	}
+	ent.value = event.Value
+	ent.frequency = max(event.Frequency, 1)
+	ent.createdAt = event.CreatedAt
+	if ent.createdAt.IsZero() {
+		ent.createdAt = c.clock()
+	}
+	ent.expiresAt = event.ExpiresAt
+	c.insert(ent)
+}
+
+// Checksum fingerprints the live entries, so that two caches holding the
+// same keys and values, such as a primary and its replica, return the
+// same value whatever order the entries were inserted in. Frequencies and
+// expiry times are not included. Entries are hashed with the function set
+// by WithEntryHasher, or else their fmt representation.
+func (c *LFUCache[K, V]) Checksum() uint64 {
+	c.rlock()
+	defer c.mu.RUnlock()
+	hash := c.entryHasher
+	if hash == nil {
+		hash = defaultEntryHash[K, V]
+	}
+	now := c.clock()
+	var sum uint64
+	for key, ent := range c.keyMap {
+		if c.overdue(ent, now) <= 0 {
+			sum ^= hash(key, ent.value)
+		}
+	}
+	return sum
+}
+
+// defaultEntryHash hashes the fmt representation of key and value with
+// FNV-1a.
+func defaultEntryHash[K comparable, V any](key K, value V) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%#v\x00%#v", key, value)
+	return h.Sum64()
+}
@@ -0,0 +1,211 @@
+package lfu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func drainEvents[K comparable, V any](ch chan ChangeEvent[K, V]) []ChangeEvent[K, V] {
+	var events []ChangeEvent[K, V]
+	for {
+		select {
+		case ev := <-ch:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+// Test every mutation is published on the change feed
+func TestChangeFeed(t *testing.T) {
+	ch := make(chan ChangeEvent[string, int], 16)
+	cache := New(1, 30*time.Millisecond, 0, nil, WithChangeFeed[string, int](ch))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	cache.Set("b", 3) // evicts a
+	cache.Delete("b")
+	cache.Set("c", 4)
+	time.Sleep(50 * time.Millisecond)
+	cache.Get("c") // expires c
+
+	want := []struct {
+		op    ChangeOp
+		key   string
+		value int
+	}{
+		{OpSet, "a", 1},
+		{OpSet, "a", 2},
+		{OpEvict, "a", 2},
+		{OpSet, "b", 3},
+		{OpDelete, "b", 3},
+		{OpSet, "c", 4},
+		{OpExpire, "c", 4},
+	}
+	events := drainEvents(ch)
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		ev := events[i]
+		if ev.Op != w.op || ev.Key != w.key || ev.Value != w.value {
+			t.Errorf("Event %d: expected %v %s=%d, got %v %s=%d",
+				i, w.op, w.key, w.value, ev.Op, ev.Key, ev.Value)
+		}
+	}
+}
+
+// Test a full feed drops events instead of blocking
+func TestChangeFeedDrops(t *testing.T) {
+	ch := make(chan ChangeEvent[string, int], 1)
+	cache := newTestCache[string, int](4, time.Minute, nil, WithChangeFeed[string, int](ch))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	if n := cache.DroppedChanges(); n != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", n)
+	}
+}
+
+// Test a replica applying the feed mirrors the primary quietly
+func TestApply(t *testing.T) {
+	feed := make(chan ChangeEvent[string, int], 16)
+	primary := newTestCache[string, int](2, time.Minute, nil, WithChangeFeed[string, int](feed))
+
+	replicaFeed := make(chan ChangeEvent[string, int], 16)
+	replica := newTestCache[string, int](2, time.Minute, nil, WithChangeFeed[string, int](replicaFeed))
+
+	primary.Set("a", 1)
+	primary.Set("a", 2) // frequency 2
+	primary.Set("b", 3)
+	primary.Set("c", 4) // evicts b
+	primary.Set("c", 5)
+	primary.Delete("a")
+
+	for _, ev := range drainEvents(feed) {
+		replica.Apply(ev)
+	}
+
+	if replica.Len() != 1 {
+		t.Errorf("Expected 1 entry on the replica, got %d", replica.Len())
+	}
+	if f := frequencyOf(replica, "c"); f != 2 {
+		t.Errorf("Expected c to keep frequency 2, got %d", f)
+	}
+	if v, ok := replica.Take("c"); !ok || v != 5 {
+		t.Errorf("Expected c=5 on the replica, got %v", v)
+	}
+	if stats := replica.Stats(); stats != (CacheStats{}) {
+		t.Errorf("Expected no stats on the replica, got %+v", stats)
+	}
+	// Only the Take above should have been published
+	if events := drainEvents(replicaFeed); len(events) != 1 || events[0].Op != OpDelete {
+		t.Errorf("Expected Apply not to re-publish events, got %+v", events)
+	}
+}
+
+// Test replicas keep the deadline of entries set with SetWithDeadline
+func TestApplyDeadline(t *testing.T) {
+	now := time.Now()
+	clock := WithClock[string, int](func() time.Time { return now })
+	feed := make(chan ChangeEvent[string, int], 16)
+	primary := New(4, time.Minute, 0, nil, clock, WithChangeFeed[string, int](feed))
+	defer primary.Stop()
+	replica := New(4, time.Minute, 0, nil, clock)
+	defer replica.Stop()
+
+	primary.Set("a", 1)
+	primary.SetWithDeadline("a", 2, now.Add(10*time.Second))
+	primary.SetWithDeadline("b", 3, now.Add(10*time.Second))
+	for _, ev := range drainEvents(feed) {
+		if ev.Op == OpSet && ev.ExpiresAt.IsZero() && ev.Value != 1 {
+			t.Errorf("Expected the set of %s=%d to carry its deadline", ev.Key, ev.Value)
+		}
+		replica.Apply(ev)
+	}
+
+	now = now.Add(20 * time.Second)
+	if replica.Contains("a") || replica.Contains("b") {
+		t.Errorf("Expected the replica to expire a and b at their deadline")
+	}
+}
+
+// Test caches with the same contents have the same checksum
+func TestChecksum(t *testing.T) {
+	a := newTestCache[string, int](8, time.Minute, nil)
+	b := newTestCache[string, int](8, time.Minute, nil)
+	for i := 0; i < 5; i++ {
+		a.Set(fmt.Sprint("key-", i), i)
+		b.Set(fmt.Sprint("key-", 4-i), 4-i)
+	}
+	b.Get("key-1") // frequencies don't count
+
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("Expected equal checksums for equal contents")
+	}
+	b.Set("key-1", 10)
+	if a.Checksum() == b.Checksum() {
+		t.Errorf("Expected different checksums after b changed")
+	}
+	a.Set("key-1", 10)
+	a.Set("key-5", 5)
+	a.Delete("key-5")
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("Expected equal checksums once a caught up")
+	}
+
+	empty := newTestCache[string, int](8, time.Minute, nil)
+	if empty.Checksum() != 0 {
+		t.Errorf("Expected 0 for an empty cache, got %d", empty.Checksum())
+	}
+}
+
+// Test WithEntryHasher replaces the default entry hash
+func TestChecksumEntryHasher(t *testing.T) {
+	cache := newTestCache(8, time.Minute, nil, WithEntryHasher(func(k string, v int) uint64 {
+		return uint64(v)
+	}))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if sum := cache.Checksum(); sum != 3 {
+		t.Errorf("Expected 1^2=3, got %d", sum)
+	}
+}
+
+// Test replicas follow the lifetime GetAndRefresh gives an entry
+func TestApplyGetAndRefresh(t *testing.T) {
+	now := time.Now()
+	clock := WithClock[string, int](func() time.Time { return now })
+	feed := make(chan ChangeEvent[string, int], 16)
+	primary := New(4, time.Minute, 0, nil, clock, WithChangeFeed[string, int](feed))
+	defer primary.Stop()
+	replica := New(4, time.Minute, 0, nil, clock)
+	defer replica.Stop()
+
+	primary.Set("a", 1)
+	primary.Set("b", 2)
+	now = now.Add(50 * time.Second)
+	primary.GetAndRefresh("a", 5*time.Minute)
+	primary.GetAndRefresh("b", 0)
+	events := drainEvents(feed)
+	if last := events[len(events)-2]; !last.ExpiresAt.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Expected the refresh of a to carry its new expiry, got %v", last.ExpiresAt)
+	}
+	for _, ev := range events {
+		replica.Apply(ev)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !replica.Contains("a") || replica.Contains("b") {
+		t.Errorf("Expected a to outlive its original TTL on the replica and b to expire")
+	}
+	now = now.Add(4 * time.Minute)
+	if replica.Contains("a") {
+		t.Errorf("Expected a to expire at its new deadline on the replica")
+	}
+}
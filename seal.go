@@ -0,0 +1,37 @@
+package lfu
+
+import "errors"
+
+// ErrCacheSealed is returned by SetWithError and Transaction once the cache
+// has been sealed.
+var ErrCacheSealed = errors.New("cache sealed")
+
+// Seal makes the cache read-only. Afterwards every mutation is a no-op:
+// Sets are dropped, with SetWithError and Transaction returning
+// ErrCacheSealed, and Deletes, evictions, expiry cleanup and frequency
+// changes no longer happen. Gets still work and count hits and misses, but
+// don't touch frequencies, so they only need the read lock. Expired
+// entries read as misses. Loaded values are returned but not cached.
+// Sealing can't be undone.
+func (c *LFUCache[K, V]) Seal() {
+	c.lock()
+	c.drainPending()
+	c.sealed.Store(true)
+	c.unlock()
+}
+
+// Sealed reports whether Seal was called.
+func (c *LFUCache[K, V]) Sealed() bool {
+	return c.sealed.Load()
+}
+
+// lockUnsealed acquires the write lock and reports whether it did, which
+// it doesn't once the cache is sealed.
+func (c *LFUCache[K, V]) lockUnsealed() bool {
+	c.lock()
+	if c.sealed.Load() {
+		c.unlock()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package lfu
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test a sealed cache rejects mutations but keeps serving reads
+func TestSeal(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Seal()
+
+	if !cache.Sealed() {
+		t.Errorf("Expected the cache to report it is sealed")
+	}
+	if err := cache.SetWithError("c", 3); !errors.Is(err, ErrCacheSealed) {
+		t.Errorf("Expected %v, got %v", ErrCacheSealed, err)
+	}
+	cache.Set("a", 10)
+	cache.SetWithTags("b", 20, "tag")
+	if cache.Delete("b") {
+		t.Errorf("Expected Delete to report nothing removed")
+	}
+	if _, ok := cache.Take("a"); ok {
+		t.Errorf("Expected Take to fail")
+	}
+	if cache.Rename("a", "z") {
+		t.Errorf("Expected Rename to fail")
+	}
+	cache.UpdateAll(func(string, int) (int, bool) { return 0, false })
+	cache.ReplaceAll(map[string]int{"x": 1})
+	err := cache.Transaction(func(tx *Tx[string, int]) error {
+		tx.Set("c", 3)
+		return nil
+	})
+	if !errors.Is(err, ErrCacheSealed) {
+		t.Errorf("Expected %v, got %v", ErrCacheSealed, err)
+	}
+
+	for key, want := range map[string]int{"a": 1, "b": 2} {
+		if v, ok := cache.Get(key); !ok || v != want {
+			t.Errorf("Expected (%d, true) for %s, got (%d, %v)", want, key, v, ok)
+		}
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+	if f := frequencyOf(cache, "a"); f != 1 {
+		t.Errorf("Expected reads not to change frequencies, got %d", f)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("Expected reads to count as hits, got %d", stats.Hits)
+	}
+}
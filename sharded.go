@@ -0,0 +1,146 @@
+package lfu
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ShardedCache spreads keys over several independent caches to reduce
+// lock contention. Each key always maps to the same shard.
+type ShardedCache[K comparable, V any] struct {
+	shards []*LFUCache[K, V]
+	hash   func(K) uint64
+}
+
+// NewSharded creates a cache split into shards caches that share capacity
+// evenly, rounding up, and are each created with the given options.
+func NewSharded[K comparable, V any](
+	shards int,
+	capacity int,
+	ttl time.Duration,
+	cleanupInterval time.Duration,
+	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
+) *ShardedCache[K, V] {
+	shards = max(shards, 1)
+	perShard := (capacity + shards - 1) / shards
+	s := &ShardedCache[K, V]{shards: make([]*LFUCache[K, V], shards)}
+	for i := range s.shards {
+		s.shards[i] = New(perShard, ttl, cleanupInterval, onEvict, opts...)
+	}
+	s.hash = s.shards[0].hasher
+	if s.hash == nil {
+		s.hash = defaultHash[K]
+	}
+	return s
+}
+
+// defaultHash hashes the key's bytes, or its fmt representation for
+// non-string keys, with FNV-1a, so the mapping is the same in every process.
+func defaultHash[K comparable](key K) uint64 {
+	h := fnv.New64a()
+	if s, ok := any(key).(string); ok {
+		h.Write([]byte(s))
+	} else {
+		fmt.Fprint(h, key)
+	}
+	return h.Sum64()
+}
+
+// ShardFor returns the index of the shard key maps to, for routing keys
+// to the same shard from outside the cache. Keys are mapped with jump
+// consistent hashing, so a router going from n to n+1 shards only moves
+// about 1/(n+1) of the keys, all of them to the new shard.
+func (s *ShardedCache[K, V]) ShardFor(key K) int {
+	return jumpHash(s.hash(key), len(s.shards))
+}
+
+// jumpHash maps hash to one of buckets buckets with Lamping and Veach's
+// jump consistent hash.
+func jumpHash(hash uint64, buckets int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(buckets) {
+		b = j
+		hash = hash*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+	}
+	return int(b)
+}
+
+func (s *ShardedCache[K, V]) shard(key K) *LFUCache[K, V] {
+	return s.shards[s.ShardFor(key)]
+}
+
+func (s *ShardedCache[K, V]) Get(key K) (V, bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *ShardedCache[K, V]) Set(key K, value V) {
+	s.shard(key).Set(key, value)
+}
+
+func (s *ShardedCache[K, V]) Delete(key K) bool {
+	return s.shard(key).Delete(key)
+}
+
+func (s *ShardedCache[K, V]) Peek(key K) (V, bool) {
+	return s.shard(key).Peek(key)
+}
+
+func (s *ShardedCache[K, V]) Contains(key K) bool {
+	return s.shard(key).Contains(key)
+}
+
+// Keys returns the live keys of all shards in no particular order.
+func (s *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, c := range s.shards {
+		keys = append(keys, c.Keys()...)
+	}
+	return keys
+}
+
+// Range calls fn for each live entry, shard by shard and coldest first
+// within a shard, until fn returns false.
+func (s *ShardedCache[K, V]) Range(fn func(key K, value V) bool) {
+	for _, c := range s.shards {
+		done := false
+		c.Range(func(key K, value V) bool {
+			done = !fn(key, value)
+			return !done
+		})
+		if done {
+			return
+		}
+	}
+}
+
+// Len returns the number of entries across all shards.
+func (s *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// Stats returns the stats summed across all shards.
+func (s *ShardedCache[K, V]) Stats() CacheStats {
+	var total CacheStats
+	for _, c := range s.shards {
+		st := c.Stats()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Evictions += st.Evictions
+		total.Rejections += st.Rejections
+	}
+	return total
+}
+
+// Stop stops every shard.
+func (s *ShardedCache[K, V]) Stop() {
+	for _, c := range s.shards {
+		c.Stop()
+	}
+}
@@ -0,0 +1,89 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test keys spread evenly across shards and always route the same way
+func TestShardForDistribution(t *testing.T) {
+	s := NewSharded[int, int](8, 1000, time.Minute, 0, nil)
+	defer s.Stop()
+
+	const keys = 80000
+	counts := make([]int, 8)
+	for i := 0; i < keys; i++ {
+		counts[s.ShardFor(i)]++
+	}
+	for i, n := range counts {
+		if n < keys/8*9/10 || n > keys/8*11/10 {
+			t.Errorf("Expected shard %d to get about %d keys, got %d", i, keys/8, n)
+		}
+	}
+
+	s.Set(42, 1)
+	if v, ok := s.shards[s.ShardFor(42)].Peek(42); !ok || v != 1 {
+		t.Errorf("Expected 42 to be stored in the shard ShardFor names")
+	}
+}
+
+// Test WithHasher overrides the shard mapping
+func TestWithHasher(t *testing.T) {
+	s := NewSharded(4, 100, time.Minute, 0, nil,
+		WithHasher[int, int](func(k int) uint64 { return uint64(k) }))
+	defer s.Stop()
+
+	for k := 0; k < 8; k++ {
+		if got, want := s.ShardFor(k), jumpHash(uint64(k), 4); got != want {
+			t.Errorf("Expected key %d on shard %d, got %d", k, want, got)
+		}
+	}
+	s.Set(1, 1)
+	if v, ok := s.Get(1); !ok || v != 1 || s.Len() != 1 {
+		t.Errorf("Expected 1=1 through the sharded cache")
+	}
+}
+
+// Test a ShardedCache can be used through the Cache interface
+func TestShardedCacheInterface(t *testing.T) {
+	var c Cache[int, int] = NewSharded[int, int](4, 100, time.Minute, 0, nil)
+	defer c.Stop()
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+	if len(c.Keys()) != 10 || !c.Contains(3) {
+		t.Errorf("Expected all 10 keys, got %v", c.Keys())
+	}
+	visited := 0
+	c.Range(func(k, v int) bool {
+		visited++
+		return visited < 5
+	})
+	if visited != 5 {
+		t.Errorf("Expected Range to stop after 5 entries, visited %d", visited)
+	}
+}
+
+// Test adding a shard only moves keys to the new shard, and few of them
+func TestShardForConsistent(t *testing.T) {
+	small := NewSharded[int, int](8, 100, time.Minute, 0, nil)
+	defer small.Stop()
+	large := NewSharded[int, int](9, 100, time.Minute, 0, nil)
+	defer large.Stop()
+
+	const keys = 90000
+	moved := 0
+	for i := 0; i < keys; i++ {
+		from, to := small.ShardFor(i), large.ShardFor(i)
+		if from == to {
+			continue
+		}
+		moved++
+		if to != 8 {
+			t.Fatalf("Expected key %d to move to the new shard 8, got %d", i, to)
+		}
+	}
+	if moved < keys/9*9/10 || moved > keys/9*11/10 {
+		t.Errorf("Expected about %d keys to move, got %d", keys/9, moved)
+	}
+}
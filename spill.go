@@ -0,0 +1,173 @@
+package lfu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SpillStore keeps entries evicted from a cache configured with
+// WithSpillover, typically somewhere cheaper than memory such as disk.
+type SpillStore[K comparable, V any] interface {
+	Put(key K, value V) error
+	Get(key K) (V, bool, error)
+	Delete(key K) error
+}
+
+// spillOp is a SpillStore write queued while c.mu is held.
+type spillOp[K comparable, V any] struct {
+	key   K
+	value V
+	put   bool
+}
+
+// spillEvicted queues writing ent to the spill store. Must be called with
+// c.mu held.
+func (c *LFUCache[K, V]) spillEvicted(ent *entry[K, V]) {
+	if c.spilled == nil {
+		c.spilled = make(map[K]*entry[K, V])
+	}
+	c.spilled[ent.key] = &entry[K, V]{key: ent.key, tags: ent.tags, dependsOn: ent.dependsOn}
+	c.spillOps = append(c.spillOps, spillOp[K, V]{key: ent.key, value: ent.value, put: true})
+}
+
+// unspill queues deleting the spilled copy of key, if there is one. Must
+// be called with c.mu held.
+func (c *LFUCache[K, V]) unspill(key K) {
+	if meta, ok := c.spilled[key]; ok {
+		delete(c.spilled, key)
+		c.spillOps = append(c.spillOps, spillOp[K, V]{key: key})
+		c.unpark(meta)
+	}
+}
+
+// runSpill applies ops to the spill store, logging failures.
+func (c *LFUCache[K, V]) runSpill(ops []spillOp[K, V]) {
+	for _, op := range ops {
+		if op.put {
+			if err := c.spill.Put(op.key, op.value); err != nil {
+				c.log("spill failed", "key", op.key, "error", err)
+			}
+		} else if err := c.spill.Delete(op.key); err != nil {
+			c.log("spill delete failed", "key", op.key, "error", err)
+		}
+	}
+}
+
+// promoteSpilled reads key back from the spill store after a miss and
+// caches it again with its tags and dependencies, counting the read as an
+// access.
+func (c *LFUCache[K, V]) promoteSpilled(key K) (V, bool) {
+	var zero V
+	c.rlock()
+	_, ok := c.spilled[key]
+	c.mu.RUnlock()
+	if !ok {
+		return zero, false
+	}
+	value, found, err := c.spill.Get(key)
+	if err != nil {
+		c.log("spill read failed", "key", key, "error", err)
+	}
+	if !found {
+		return zero, false
+	}
+
+	c.lock()
+	defer c.unlock()
+	meta, ok := c.spilled[key]
+	if !ok {
+		return zero, false // deleted, set again or invalidated since the miss
+	}
+	c.set(key, value) // drops the spilled copy
+	ent, ok := c.keyMap[key]
+	if !ok {
+		return zero, false
+	}
+	if meta.tags != nil {
+		ent.tags = meta.tags
+		c.indexTags(ent)
+	}
+	if meta.dependsOn != nil {
+		ent.dependsOn = meta.dependsOn
+		c.indexDependencies(ent)
+	}
+	ent.accessCount++
+	c.increment(ent)
+	return value, true
+}
+
+// FileSpillStore is a SpillStore keeping each entry in its own file in a
+// directory, encoded with GobCodec. Files are named after a hash of the
+// key, so two keys with the same hash share a file and the one put last
+// wins. It is safe for concurrent use on distinct keys.
+type FileSpillStore[K comparable, V any] struct {
+	dir   string
+	codec GobCodec[K, V]
+}
+
+// NewFileSpillStore returns a FileSpillStore writing to dir, which is
+// created if needed.
+func NewFileSpillStore[K comparable, V any](dir string) (*FileSpillStore[K, V], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSpillStore[K, V]{dir: dir}, nil
+}
+
+func (s *FileSpillStore[K, V]) path(key K) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016x.spill", defaultHash(key)))
+}
+
+// Put writes the entry to a temporary file first, so readers never see a
+// partial one.
+func (s *FileSpillStore[K, V]) Put(key K, value V) error {
+	var buf bytes.Buffer
+	if err := s.codec.Encode(&buf, key, value); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, "put-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *FileSpillStore[K, V]) Get(key K) (V, bool, error) {
+	var zero V
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return zero, false, nil
+	}
+	if err != nil {
+		return zero, false, err
+	}
+	stored, value, err := s.codec.Decode(bytes.NewReader(data))
+	if err != nil {
+		return zero, false, err
+	}
+	if stored != key {
+		return zero, false, nil // another key with the same hash
+	}
+	return value, true, nil
+}
+
+func (s *FileSpillStore[K, V]) Delete(key K) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package lfu
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapSpillStore is an in-memory SpillStore for tests.
+type mapSpillStore struct {
+	mu   sync.Mutex
+	data map[string]int
+}
+
+func (s *mapSpillStore) Put(key string, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *mapSpillStore) Get(key string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok, nil
+}
+
+func (s *mapSpillStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// Test evicted entries are spilled to disk and reloaded on a miss
+func TestSpillover(t *testing.T) {
+	store, err := NewFileSpillStore[string, int](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newTestCache[string, int](2, time.Minute, nil, WithSpillover[string, int](store))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Set("b", 2)
+	cache.Set("c", 3) // evicts b to the store
+
+	if v, ok, _ := store.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 in the spill store, got %v, %v", v, ok)
+	}
+	if v, ok := cache.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 reloaded from the spill store, got %v, %v", v, ok)
+	}
+	if _, ok, _ := store.Get("b"); ok {
+		t.Errorf("Expected b to be removed from the store once reloaded")
+	}
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 0 {
+		t.Errorf("Expected the reload to count as a hit, got %+v", s)
+	}
+	if v, ok, _ := store.Get("c"); !ok || v != 3 {
+		t.Errorf("Expected c evicted to make room for b to be spilled, got %v, %v", v, ok)
+	}
+}
+
+// Test deleted and overwritten keys are not reloaded from the store
+func TestSpilloverDelete(t *testing.T) {
+	store := &mapSpillStore{data: make(map[string]int)}
+	cache := newTestCache[string, int](1, time.Minute, nil, WithSpillover[string, int](store))
+	cache.Set("a", 1)
+	cache.Set("b", 2) // spills a
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected deleted a not to be reloaded")
+	}
+	if len(store.data) != 0 {
+		t.Errorf("Expected Delete to drop the spilled copy, got %v", store.data)
+	}
+
+	cache.Set("c", 3) // spills b
+	cache.Set("b", 20)
+	if len(store.data) != 1 || store.data["c"] != 3 {
+		t.Errorf("Expected Set to drop the spilled copy of b, got %v", store.data)
+	}
+	if v, _ := cache.Get("b"); v != 20 {
+		t.Errorf("Expected b=20, got %d", v)
+	}
+}
+
+// Test the file store misses keys it doesn't hold
+func TestFileSpillStoreMiss(t *testing.T) {
+	store, err := NewFileSpillStore[string, int](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.Get("b"); ok || err != nil {
+		t.Errorf("Expected a miss for a key never put, got %v, %v", ok, err)
+	}
+	if err := store.Delete("b"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+}
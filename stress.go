@@ -0,0 +1,179 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// StressConfig controls StressTest.
+type StressConfig[K comparable, V any] struct {
+	Goroutines int       // concurrent workers, defaults to 8
+	Operations int       // operations per worker, defaults to 1000
+	Keys       []K       // keys to operate on, required
+	Value      func(K) V // value to Set for a key, the zero value if nil
+	Seed       int64     // seeds each worker's random sequence
+}
+
+// StressTest runs randomized concurrent Gets, Sets and Deletes against c
+// and then verifies the cache's internal invariants, returning an error
+// describing the first one that no longer holds. Run it with the race
+// detector enabled to also catch unsynchronized access, for example from
+// custom options or callbacks.
+func StressTest[K comparable, V any](c *LFUCache[K, V], cfg StressConfig[K, V]) error {
+	if len(cfg.Keys) == 0 {
+		return errors.New("StressTest needs at least one key")
+	}
+	if cfg.Goroutines <= 0 {
+		cfg.Goroutines = 8
+	}
+	if cfg.Operations <= 0 {
+		cfg.Operations = 1000
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Goroutines; i++ {
+		wg.Add(1)
+		go func(r *rand.Rand) {
+			defer wg.Done()
+			for n := 0; n < cfg.Operations; n++ {
+				key := cfg.Keys[r.Intn(len(cfg.Keys))]
+				switch op := r.Intn(10); {
+				case op < 5:
+					c.Get(key)
+				case op < 9:
+					var value V
+					if cfg.Value != nil {
+						value = cfg.Value(key)
+					}
+					c.Set(key, value)
+				default:
+					c.Delete(key)
+				}
+			}
+		}(rand.New(rand.NewSource(cfg.Seed + int64(i))))
+	}
+	wg.Wait()
+	return c.checkInvariants()
+}
+
+// checkInvariants verifies that the key map, the frequency buckets, the
+// size, minFreq, the tag index and the dependency index, which also covers
+// evicted copies, agree with each other.
+func (c *LFUCache[K, V]) checkInvariants() error {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	if c.size != len(c.keyMap) {
+		return fmt.Errorf("size %d but %d keys mapped", c.size, len(c.keyMap))
+	}
+	if c.capacity >= 0 && c.size > c.capacity {
+		return fmt.Errorf("size %d exceeds capacity %d", c.size, c.capacity)
+	}
+
+	linked := 0
+	lowest := 0
+	for freq, list := range c.freqMap {
+		if list.isEmpty() {
+			return fmt.Errorf("empty bucket for frequency %d", freq)
+		}
+		if lowest == 0 || freq < lowest {
+			lowest = freq
+		}
+		var err error
+		count := 0
+		list.eachNewest(func(ent *entry[K, V]) bool {
+			switch {
+			case ent.frequency != freq:
+				err = fmt.Errorf("key %v has frequency %d but sits in bucket %d", ent.key, ent.frequency, freq)
+			case !list.owns(ent):
+				err = fmt.Errorf("key %v points at a stale list position", ent.key)
+			case c.keyMap[ent.key] != ent:
+				err = fmt.Errorf("key %v is bucketed but not mapped", ent.key)
+			}
+			count++
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		if count != list.len() {
+			return fmt.Errorf("bucket %d holds %d entries but counts %d", freq, count, list.len())
+		}
+		linked += count
+	}
+	if linked != c.size {
+		return fmt.Errorf("%d entries bucketed but size is %d", linked, c.size)
+	}
+	if linked > 0 && c.minFreq > lowest {
+		return fmt.Errorf("minFreq %d above the lowest bucket %d", c.minFreq, lowest)
+	}
+
+	for tag, keys := range c.tags {
+		for key := range keys {
+			ent, ok := c.keyMap[key]
+			if !ok {
+				return fmt.Errorf("tag %q indexes missing key %v", tag, key)
+			}
+			found := false
+			for _, t := range ent.tags {
+				found = found || t == tag
+			}
+			if !found {
+				return fmt.Errorf("tag %q indexes key %v that does not carry it", tag, key)
+			}
+		}
+	}
+
+	for dep, keys := range c.dependents {
+		for key := range keys {
+			copies := c.copiesOf(key)
+			if len(copies) == 0 {
+				return fmt.Errorf("dependency %v indexes missing key %v", dep, key)
+			}
+			found := false
+			for _, ent := range copies {
+				for _, d := range ent.dependsOn {
+					found = found || d == dep
+				}
+			}
+			if !found {
+				return fmt.Errorf("dependency %v indexes key %v that does not depend on it", dep, key)
+			}
+		}
+	}
+	for key := range c.keyMap {
+		for _, ent := range c.copiesOf(key) {
+			if ent != c.keyMap[key] {
+				return fmt.Errorf("key %v is both live and evicted", key)
+			}
+		}
+	}
+	check := func(ent *entry[K, V]) error {
+		for _, dep := range ent.dependsOn {
+			if _, ok := c.dependents[dep][ent.key]; !ok {
+				return fmt.Errorf("key %v depends on %v but is not indexed", ent.key, dep)
+			}
+		}
+		return nil
+	}
+	for _, ent := range c.keyMap {
+		if err := check(ent); err != nil {
+			return err
+		}
+	}
+	if c.victims != nil {
+		for e := c.victims.items.Front(); e != nil; e = e.Next() {
+			if err := check(e.Value.(*entry[K, V])); err != nil {
+				return err
+			}
+		}
+	}
+	for _, ent := range c.spilled {
+		if err := check(ent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test invariants hold after concurrent randomized operations
+func TestStress(t *testing.T) {
+	keys := make([]int, 64)
+	for i := range keys {
+		keys[i] = i
+	}
+	opts := map[string][]Option[int, int]{
+		"default":  nil,
+		"deferred": {WithDeferredIncrements[int, int](true)},
+		"sampled":  {WithSampledEviction[int, int](5)},
+		"buckets":  {WithMaxBuckets[int, int](3)},
+		"victims":  {WithVictimCache[int, int](8)},
+		"compact":  {WithCompactLowFrequency[int, int](true)},
+	}
+	for name, o := range opts {
+		cache := newTestCache[int, int](16, time.Minute, nil, o...)
+		err := StressTest(cache, StressConfig[int, int]{
+			Keys:  keys,
+			Value: func(k int) int { return k * 2 },
+			Seed:  1,
+		})
+		if err != nil {
+			t.Errorf("Expected invariants to hold with %s options, got %v", name, err)
+		}
+		cache.Stop()
+	}
+}
+
+// Test checkInvariants catches a corrupted cache
+func TestCheckInvariants(t *testing.T) {
+	cache := newTestCache[int, int](4, time.Minute, nil)
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+
+	cache.keyMap[1].frequency = 7
+	if err := cache.checkInvariants(); err == nil {
+		t.Errorf("Expected a frequency mismatch to be reported")
+	}
+}
@@ -0,0 +1,84 @@
+package lfu
+
+import "slices"
+
+// SetWithTags inserts or updates key and labels it with tags, replacing any
+// tags it had, so that InvalidateTag can remove it along with every other
+// entry sharing a tag. A plain Set keeps the key's existing tags. Errors
+// are dropped like with Set.
+func (c *LFUCache[K, V]) SetWithTags(key K, value V, tags ...string) {
+	_ = c.writeLocked(key, value, func() {
+		c.set(key, value)
+		ent, ok := c.keyMap[key]
+		if !ok {
+			return
+		}
+		c.untag(ent)
+		ent.tags = nil
+		seen := make(map[string]struct{}, len(tags))
+		for _, tag := range tags {
+			if _, dup := seen[tag]; !dup {
+				seen[tag] = struct{}{}
+				ent.tags = append(ent.tags, tag)
+			}
+		}
+		c.indexTags(ent)
+	})
+}
+
+// InvalidateTag deletes every entry tagged with tag and returns how many
+// were removed. Like Delete, removals are not counted as evictions and do
+// not invoke the eviction callback. Evicted copies of tagged entries kept
+// for WithVictimCache or WithSpillover are dropped too.
+func (c *LFUCache[K, V]) InvalidateTag(tag string) int {
+	if !c.lockUnsealed() {
+		return 0
+	}
+	defer c.unlock()
+	removed := 0
+	for key := range c.tags[tag] {
+		if c.del(key) {
+			removed++
+		}
+	}
+	if c.victims != nil {
+		for _, ent := range c.victims.removeTagged(tag) {
+			c.queueClose(ent.value)
+			c.unpark(ent)
+		}
+	}
+	for key, meta := range c.spilled {
+		if slices.Contains(meta.tags, tag) {
+			c.unspill(key)
+		}
+	}
+	return removed
+}
+
+// indexTags adds ent to the index of each of its tags. Must be called with
+// c.mu held.
+func (c *LFUCache[K, V]) indexTags(ent *entry[K, V]) {
+	if c.tags == nil && len(ent.tags) > 0 {
+		c.tags = make(map[string]map[K]struct{})
+	}
+	for _, tag := range ent.tags {
+		keys := c.tags[tag]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			c.tags[tag] = keys
+		}
+		keys[ent.key] = struct{}{}
+	}
+}
+
+// untag drops ent from the tag index, leaving ent.tags as they are. Must
+// be called with c.mu held.
+func (c *LFUCache[K, V]) untag(ent *entry[K, V]) {
+	for _, tag := range ent.tags {
+		keys := c.tags[tag]
+		delete(keys, ent.key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
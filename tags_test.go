@@ -0,0 +1,69 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test InvalidateTag removes every entry sharing the tag
+func TestInvalidateTag(t *testing.T) {
+	cache := newTestCache[string, int](10, time.Minute, nil)
+	cache.SetWithTags("user:1", 1, "user", "team:a")
+	cache.SetWithTags("user:2", 2, "user", "team:b")
+	cache.SetWithTags("team:a", 3, "team:a")
+	cache.Set("other", 4)
+
+	if n := cache.InvalidateTag("team:a"); n != 2 {
+		t.Errorf("Expected 2 entries invalidated, got %d", n)
+	}
+	if cache.Contains("user:1") || cache.Contains("team:a") {
+		t.Errorf("Expected entries tagged team:a to be removed")
+	}
+	if n := cache.InvalidateTag("user"); n != 1 {
+		t.Errorf("Expected only user:2 left under user, got %d", n)
+	}
+	if cache.Len() != 1 || !cache.Contains("other") {
+		t.Errorf("Expected only the untagged entry to remain")
+	}
+	if len(cache.tags) != 0 {
+		t.Errorf("Expected the tag index to be empty, got %v", cache.tags)
+	}
+}
+
+// Test tags are dropped when tagged entries are evicted
+func TestTagsCleanedOnEviction(t *testing.T) {
+	cache := newTestCache[int, int](1, time.Minute, nil)
+	cache.SetWithTags(1, 1, "t")
+	cache.Set(2, 2)
+
+	if n := cache.InvalidateTag("t"); n != 0 {
+		t.Errorf("Expected no entries under an evicted tag, got %d", n)
+	}
+	if !cache.Contains(2) {
+		t.Errorf("Expected 2 to survive")
+	}
+	if len(cache.tags) != 0 {
+		t.Errorf("Expected the tag index to be empty, got %v", cache.tags)
+	}
+}
+
+// Test spilled entries keep their tags and are dropped by InvalidateTag
+func TestInvalidateTagSpilled(t *testing.T) {
+	store := &mapSpillStore{data: map[string]int{}}
+	cache := newTestCache[string, int](1, time.Minute, nil, WithSpillover[string, int](store))
+	cache.SetWithTags("a", 1, "t")
+	cache.Set("b", 2) // spills a
+	cache.InvalidateTag("t")
+	if v, ok := cache.Get("a"); ok {
+		t.Errorf("Expected the invalidated a to stay gone, got %d back from spill", v)
+	}
+
+	cache.SetWithTags("c", 3, "t")
+	cache.Set("d", 4) // spills c
+	if _, ok := cache.Get("c"); !ok {
+		t.Fatalf("Expected c back from spill")
+	}
+	if n := cache.InvalidateTag("t"); n != 1 || cache.Contains("c") {
+		t.Errorf("Expected the promoted c to keep its tag, removed %d", n)
+	}
+}
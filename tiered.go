@@ -0,0 +1,167 @@
+package lfu
+
+import "time"
+
+// TieredCache is a small L1 cache in front of a larger L2 cache. Sets go
+// to L1, entries L1 evicts for capacity move down to L2, and L2 hits move
+// back up to L1. A key lives in at most one tier.
+type TieredCache[K comparable, V any] struct {
+	l1, l2 *LFUCache[K, V]
+}
+
+// NewTiered creates a two-level cache with the given tier capacities.
+// Both tiers are created with the given options, except that the loader,
+// WithAutoClose, WithChangeFeed and WithSpillover only apply to the L2:
+// the L1 demotes its victims to the L2 instead, and misses in both tiers
+// are loaded into the L2 before being promoted. WithWriter and
+// WithWriteBehind only apply to the L1, which takes the Sets, so demotions
+// aren't written again. onEvict is only called for entries leaving the L2.
+// Demoted entries start a new TTL.
+func NewTiered[K comparable, V any](
+	l1Capacity int,
+	l2Capacity int,
+	ttl time.Duration,
+	cleanupInterval time.Duration,
+	onEvict EvictionCallback[K, V],
+	opts ...Option[K, V],
+) *TieredCache[K, V] {
+	l2Only := func(c *LFUCache[K, V]) {
+		c.writer = nil
+		c.flushBehind = nil
+	}
+	t := &TieredCache[K, V]{l2: New(l2Capacity, ttl, cleanupInterval, onEvict, append(opts[:len(opts):len(opts)], l2Only)...)}
+	demote := WithEvictionListener(func(key K, value V, reason EvictionReason) {
+		if reason == ReasonCapacity {
+			t.l2.Set(key, value)
+		}
+	})
+	l1Only := func(c *LFUCache[K, V]) {
+		c.loader = nil
+		c.autoClose = false
+		c.changes = nil
+		c.spill = nil
+	}
+	t.l1 = New(l1Capacity, ttl, cleanupInterval, nil, append(opts[:len(opts):len(opts)], demote, l1Only)...)
+	return t
+}
+
+// Get returns the value of key from L1 or, failing that, from L2, in which
+// case the entry is promoted to L1. Keys missing from both are loaded
+// through the L2's loader, if any, and promoted too.
+func (t *TieredCache[K, V]) Get(key K) (V, bool) {
+	if value, ok := t.l1.Get(key); ok {
+		return value, true
+	}
+	if value, ok := t.promote(key); ok {
+		t.l2.hits.Add(1)
+		return value, true
+	}
+	if t.l2.loader == nil {
+		t.l2.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	value, ok := t.l2.Get(key) // counts the miss and loads
+	if ok {
+		t.promote(key)
+	}
+	return value, ok
+}
+
+// promote moves key from L2 to L1, unless a newer value was set in L1
+// since the L1 miss, and returns the value as Get hands it out.
+func (t *TieredCache[K, V]) promote(key K) (V, bool) {
+	value, ok := t.l2.Take(key)
+	if !ok {
+		return value, false
+	}
+	l1 := t.l1
+	if l1.lockUnsealed() {
+		if ent, exists := l1.keyMap[key]; !exists || l1.isExpired(ent) {
+			l1.set(key, value)
+		}
+		l1.unlock()
+	}
+	return l1.copyOut(value), true
+}
+
+// Set stores key in L1, dropping any copy from L2.
+func (t *TieredCache[K, V]) Set(key K, value V) {
+	t.l2.Delete(key)
+	t.l1.Set(key, value)
+}
+
+// Delete removes key from both tiers and reports whether it was present.
+func (t *TieredCache[K, V]) Delete(key K) bool {
+	inL1 := t.l1.Delete(key)
+	inL2 := t.l2.Delete(key)
+	return inL1 || inL2
+}
+
+func (t *TieredCache[K, V]) Peek(key K) (V, bool) {
+	if value, ok := t.l1.Peek(key); ok {
+		return value, true
+	}
+	return t.l2.Peek(key)
+}
+
+func (t *TieredCache[K, V]) Contains(key K) bool {
+	return t.l1.Contains(key) || t.l2.Contains(key)
+}
+
+// Keys returns the live keys of both tiers in no particular order.
+func (t *TieredCache[K, V]) Keys() []K {
+	keys := t.l1.Keys()
+	seen := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	for _, key := range t.l2.Keys() {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Range calls fn for each live entry, L1 first, until fn returns false.
+func (t *TieredCache[K, V]) Range(fn func(key K, value V) bool) {
+	done := false
+	t.l1.Range(func(key K, value V) bool {
+		done = !fn(key, value)
+		return !done
+	})
+	if !done {
+		t.l2.Range(fn)
+	}
+}
+
+// Len returns the number of entries across both tiers.
+func (t *TieredCache[K, V]) Len() int {
+	return t.l1.Len() + t.l2.Len()
+}
+
+// Stats returns the stats of the tiers combined: hits in either tier,
+// misses in both, and evictions out of L2. Demotions from L1 are not
+// counted as evictions.
+func (t *TieredCache[K, V]) Stats() CacheStats {
+	l1, l2 := t.TierStats()
+	return CacheStats{
+		Hits:       l1.Hits + l2.Hits,
+		Misses:     l2.Misses,
+		Evictions:  l2.Evictions,
+		Rejections: l1.Rejections + l2.Rejections,
+	}
+}
+
+// TierStats returns the stats of each tier. The L1 evictions are the
+// demotions to L2, and the L1 misses are the lookups that went on to L2.
+func (t *TieredCache[K, V]) TierStats() (l1, l2 CacheStats) {
+	return t.l1.Stats(), t.l2.Stats()
+}
+
+// Stop stops both tiers.
+func (t *TieredCache[K, V]) Stop() {
+	t.l1.Stop()
+	t.l2.Stop()
+}
@@ -0,0 +1,166 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test L1 victims are demoted to L2 and L2 hits promoted back to L1
+func TestTieredPromotionDemotion(t *testing.T) {
+	var evicted []string
+	c := NewTiered[string, int](2, 2, time.Minute, 0, func(key string, _ int) {
+		evicted = append(evicted, key)
+	})
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Get("a")
+	c.Set("b", 2)
+	c.Set("c", 3) // demotes b
+	if _, ok := c.l1.Peek("b"); ok {
+		t.Errorf("Expected b to leave L1")
+	}
+	if v, ok := c.l2.Peek("b"); !ok || v != 2 {
+		t.Errorf("Expected b to be demoted to L2, got (%d, %v)", v, ok)
+	}
+
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected (2, true), got (%d, %v)", v, ok)
+	}
+	if _, ok := c.l1.Peek("b"); !ok {
+		t.Errorf("Expected b to be promoted to L1")
+	}
+	if c.l2.Contains("b") {
+		t.Errorf("Expected b to leave L2 when promoted")
+	}
+	if !c.l2.Contains("c") {
+		t.Errorf("Expected the promotion to demote c")
+	}
+
+	c.Set("d", 4) // demotes b
+	c.Set("e", 5) // demotes d, L2 evicts c
+	if len(evicted) != 1 || evicted[0] != "c" {
+		t.Errorf("Expected only c to be evicted from the cache, got %v", evicted)
+	}
+	if n := c.Len(); n != 4 {
+		t.Errorf("Expected 4 entries, got %d", n)
+	}
+
+	c.Get("missing")
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("Expected 2 hits, 1 miss and 1 eviction, got %+v", stats)
+	}
+	l1, l2 := c.TierStats()
+	if l1.Hits != 1 || l2.Hits != 1 || l1.Evictions != 4 {
+		t.Errorf("Expected a hit in each tier and 4 demotions, got %+v and %+v", l1, l2)
+	}
+}
+
+// Test a Set replaces a copy held by L2
+func TestTieredSetReplacesL2(t *testing.T) {
+	c := NewTiered[string, int](1, 2, time.Minute, 0, nil)
+	defer c.Stop()
+	c.Set("a", 1)
+	c.Set("b", 2) // demotes a
+	c.Set("a", 3)
+
+	if c.l2.Contains("a") {
+		t.Errorf("Expected the stale copy of a to leave L2")
+	}
+	if v, ok := c.Get("a"); !ok || v != 3 {
+		t.Errorf("Expected (3, true), got (%d, %v)", v, ok)
+	}
+	if !c.Delete("b") || c.Contains("b") {
+		t.Errorf("Expected b to be deleted from L2")
+	}
+}
+
+// Test the loader runs only for keys missing from both tiers
+func TestTieredLoader(t *testing.T) {
+	loads := 0
+	c := NewTiered[string, int](1, 4, time.Minute, 0, nil, WithLoader(func(string) (int, error) {
+		loads++
+		return 99, nil
+	}))
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Set("b", 2) // demotes a
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1 from L2, got (%d, %v)", v, ok)
+	}
+	if loads != 0 {
+		t.Errorf("Expected no load for a key in L2, got %d", loads)
+	}
+	if v, ok := c.Get("c"); !ok || v != 99 || loads != 1 {
+		t.Errorf("Expected c=99 loaded once, got (%d, %v) after %d loads", v, ok, loads)
+	}
+	if _, ok := c.l1.Peek("c"); !ok {
+		t.Errorf("Expected the loaded c to be promoted to L1")
+	}
+}
+
+// Test demoted values are not auto-closed while they live in L2
+func TestTieredAutoClose(t *testing.T) {
+	c := NewTiered[string, *resource](1, 1, time.Minute, 0, nil, WithAutoClose[string, *resource](true))
+	defer c.Stop()
+
+	a, b, d := &resource{}, &resource{}, &resource{}
+	c.Set("a", a)
+	c.Set("b", b) // demotes a
+	if a.closed {
+		t.Errorf("Expected the demoted a to stay open")
+	}
+	c.Set("d", d) // demotes b, evicting a from L2
+	if !a.closed || b.closed {
+		t.Errorf("Expected only a, evicted from L2, to be closed")
+	}
+}
+
+// Test a promotion doesn't overwrite a newer value set in L1
+func TestTieredPromotionKeepsNewerValue(t *testing.T) {
+	c := NewTiered[string, int](1, 4, time.Minute, 0, nil)
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Set("b", 2)     // demotes a
+	c.l1.Set("a", 10) // a Set landing between the L1 miss and the promotion
+	if v, ok := c.promote("a"); !ok || v != 1 {
+		t.Errorf("Expected to take a=1 from L2, got (%d, %v)", v, ok)
+	}
+	if v, _ := c.l1.Peek("a"); v != 10 {
+		t.Errorf("Expected L1 to keep the newer a=10, got %d", v)
+	}
+}
+
+// Test the writer sees each Set once, not again on demotion
+func TestTieredWriter(t *testing.T) {
+	w := &flakyWriter{}
+	c := NewTiered[string, int](1, 2, time.Minute, 0, nil, WithWriter(w.write))
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Set("b", 2) // demotes a
+	if w.calls != 2 {
+		t.Errorf("Expected 2 writes, got %d", w.calls)
+	}
+}
+
+// Test values promoted from L2 are handed out through the copier
+func TestTieredPromotionCopies(t *testing.T) {
+	c := NewTiered[string, []int](1, 2, time.Minute, 0, nil,
+		WithValueCopier[string, []int](func(v []int) []int { return append([]int(nil), v...) }))
+	defer c.Stop()
+
+	c.Set("a", []int{1})
+	c.Set("b", []int{2}) // demotes a
+	v, ok := c.Get("a")
+	if !ok {
+		t.Fatalf("Expected a to be promoted")
+	}
+	v[0] = 99
+	if v, _ := c.Get("a"); v[0] != 1 {
+		t.Errorf("Expected the cached value to stay [1], got %v", v)
+	}
+}
@@ -0,0 +1,73 @@
+package lfu
+
+// Tx stages Sets and Deletes for Transaction. It must not be used after
+// the transaction function returns.
+type Tx[K comparable, V any] struct {
+	cache  *LFUCache[K, V]
+	ops    []txOp[K, V]
+	staged map[K]int // index of the last op on each key
+}
+
+type txOp[K comparable, V any] struct {
+	key     K
+	value   V
+	deleted bool
+}
+
+// Set stages storing value under key.
+func (tx *Tx[K, V]) Set(key K, value V) {
+	tx.stage(txOp[K, V]{key: key, value: value})
+}
+
+// Delete stages removing key.
+func (tx *Tx[K, V]) Delete(key K) {
+	tx.stage(txOp[K, V]{key: key, deleted: true})
+}
+
+func (tx *Tx[K, V]) stage(op txOp[K, V]) {
+	tx.staged[op.key] = len(tx.ops)
+	tx.ops = append(tx.ops, op)
+}
+
+// Get returns the value of key as the transaction sees it: the last value
+// it staged for key, or otherwise the cached value, looked up like Peek.
+func (tx *Tx[K, V]) Get(key K) (V, bool) {
+	if i, ok := tx.staged[key]; ok {
+		op := tx.ops[i]
+		if op.deleted {
+			var zero V
+			return zero, false
+		}
+		return op.value, true
+	}
+	return tx.cache.Peek(key)
+}
+
+// Transaction calls fn with a Tx and, if fn returns nil, applies the Sets
+// and Deletes it staged in order under a single write lock, so other
+// callers see either none or all of them. If fn returns an error nothing
+// is applied and the error is returned. The writes are not passed to the
+// writer or write-behind, and staging more new keys than the capacity
+// evicts some of them as usual.
+func (c *LFUCache[K, V]) Transaction(fn func(tx *Tx[K, V]) error) error {
+	if c.rejectStopped() {
+		return ErrCacheStopped
+	}
+	tx := &Tx[K, V]{cache: c, staged: make(map[K]int)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if !c.lockUnsealed() {
+		return ErrCacheSealed
+	}
+	defer c.unlock()
+	for _, op := range tx.ops {
+		if op.deleted || c.deleteOnZero != nil && c.deleteOnZero(op.value) {
+			c.del(op.key)
+		} else {
+			c.set(op.key, op.value)
+		}
+	}
+	return nil
+}
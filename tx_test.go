@@ -0,0 +1,70 @@
+package lfu
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test a transaction applies its writes and sees them while staging
+func TestTransactionCommit(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	cache.Set("from", 10)
+	cache.Set("to", 0)
+
+	err := cache.Transaction(func(tx *Tx[string, int]) error {
+		from, _ := tx.Get("from")
+		to, _ := tx.Get("to")
+		tx.Set("from", from-3)
+		tx.Set("to", to+3)
+		if v, ok := tx.Get("from"); !ok || v != 7 {
+			t.Errorf("Expected the staged value (7, true), got (%d, %v)", v, ok)
+		}
+		if v, _ := cache.Get("from"); v != 10 {
+			t.Errorf("Expected the cache to hold 10 until commit, got %d", v)
+		}
+		tx.Set("tmp", 1)
+		tx.Delete("tmp")
+		if _, ok := tx.Get("tmp"); ok {
+			t.Errorf("Expected the staged delete to hide tmp")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if v, _ := cache.Get("from"); v != 7 {
+		t.Errorf("Expected from=7, got %d", v)
+	}
+	if v, _ := cache.Get("to"); v != 3 {
+		t.Errorf("Expected to=3, got %d", v)
+	}
+	if _, ok := cache.Get("tmp"); ok {
+		t.Errorf("Expected tmp to be absent")
+	}
+}
+
+// Test a failed transaction applies nothing
+func TestTransactionRollback(t *testing.T) {
+	cache := newTestCache[string, int](4, time.Minute, nil)
+	cache.Set("a", 1)
+	errAbort := errors.New("abort")
+
+	err := cache.Transaction(func(tx *Tx[string, int]) error {
+		tx.Set("a", 2)
+		tx.Set("b", 3)
+		tx.Delete("a")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("Expected %v, got %v", errAbort, err)
+	}
+
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected (1, true), got (%d, %v)", v, ok)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be absent")
+	}
+}
@@ -0,0 +1,117 @@
+package lfu
+
+import (
+	"container/list"
+	"slices"
+)
+
+// victimCache holds recently evicted entries in LRU order so that a quick
+// re-request can promote them back instead of missing.
+type victimCache[K comparable, V any] struct {
+	size  int
+	items *list.List // most recently evicted first
+	index map[K]*list.Element
+}
+
+func newVictimCache[K comparable, V any](size int) *victimCache[K, V] {
+	return &victimCache[K, V]{
+		size:  size,
+		items: list.New(),
+		index: make(map[K]*list.Element, size),
+	}
+}
+
+// add records ent and returns the least recently evicted entry if it had
+// to be dropped to stay within size.
+func (v *victimCache[K, V]) add(ent *entry[K, V]) *entry[K, V] {
+	v.take(ent.key)
+	v.index[ent.key] = v.items.PushFront(ent)
+	if v.items.Len() <= v.size {
+		return nil
+	}
+	oldest := v.items.Remove(v.items.Back()).(*entry[K, V])
+	delete(v.index, oldest.key)
+	return oldest
+}
+
+// take removes and returns the entry for key.
+func (v *victimCache[K, V]) take(key K) (*entry[K, V], bool) {
+	e, ok := v.index[key]
+	if !ok {
+		return nil, false
+	}
+	delete(v.index, key)
+	return v.items.Remove(e).(*entry[K, V]), true
+}
+
+// removeTagged removes and returns the entries carrying tag.
+func (v *victimCache[K, V]) removeTagged(tag string) []*entry[K, V] {
+	var removed []*entry[K, V]
+	for e := v.items.Front(); e != nil; {
+		next := e.Next()
+		if ent := e.Value.(*entry[K, V]); slices.Contains(ent.tags, tag) {
+			v.items.Remove(e)
+			delete(v.index, ent.key)
+			removed = append(removed, ent)
+		}
+		e = next
+	}
+	return removed
+}
+
+// clear removes and returns all entries.
+func (v *victimCache[K, V]) clear() []*entry[K, V] {
+	removed := make([]*entry[K, V], 0, v.items.Len())
+	for e := v.items.Front(); e != nil; e = e.Next() {
+		removed = append(removed, e.Value.(*entry[K, V]))
+	}
+	v.items.Init()
+	clear(v.index)
+	return removed
+}
+
+// dropVictim discards the evicted copy of key kept in the victim cache or
+// the spill store, if there is one. Must be called with c.mu held.
+func (c *LFUCache[K, V]) dropVictim(key K) {
+	if c.spill != nil {
+		c.unspill(key)
+	}
+	if c.victims == nil {
+		return
+	}
+	if ent, ok := c.victims.take(key); ok {
+		c.queueClose(ent.value)
+		c.unpark(ent)
+	}
+}
+
+// promoteVictim moves key from the victim cache back into the cache,
+// counting the request as an access, and returns its value.
+func (c *LFUCache[K, V]) promoteVictim(key K) (V, bool) {
+	c.lock()
+	defer c.unlock()
+	var zero V
+	if _, ok := c.keyMap[key]; ok {
+		return zero, false // set again since the miss
+	}
+	ent, ok := c.victims.take(key)
+	if !ok {
+		return zero, false
+	}
+	c.unpark(ent)
+	if c.isExpired(ent) {
+		c.queueClose(ent.value)
+		return zero, false
+	}
+	c.makeRoom()
+	ent.seq = c.nextSeq
+	c.nextSeq++
+	c.insert(ent)
+	if c.keyMap[key] != ent {
+		return ent.value, true // too large for the memory limit, evicted again
+	}
+	ent.accessCount++
+	c.increment(ent)
+	c.emit(OpSet, ent)
+	return ent.value, true
+}
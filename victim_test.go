@@ -0,0 +1,54 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// Test evicted entries are promoted back from the victim cache
+func TestVictimCache(t *testing.T) {
+	cache := newTestCache[string, int](2, time.Minute, nil, WithVictimCache[string, int](1))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Set("b", 2)
+	cache.Set("c", 3) // evicts b into the victim cache
+
+	if v, ok := cache.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 promoted from the victim cache, got %v", v)
+	}
+	if f := frequencyOf(cache, "b"); f != 2 {
+		t.Errorf("Expected b to keep its frequency and count the hit, got %d", f)
+	}
+	if cache.Len() != 2 || cache.Contains("c") {
+		t.Errorf("Expected c to be evicted to make room for b")
+	}
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 0 {
+		t.Errorf("Expected the promotion to count as a hit, got %+v", s)
+	}
+
+	cache.Set("d", 4) // evicts c, pushing it out of the one-slot buffer
+	cache.Set("e", 5)
+	if _, ok := cache.Get("c"); ok {
+		t.Errorf("Expected c to have left the victim cache")
+	}
+}
+
+// Test deleted keys are not resurrected from the victim cache
+func TestVictimCacheDelete(t *testing.T) {
+	cache := newTestCache[string, int](1, time.Minute, nil, WithVictimCache[string, int](4))
+	cache.SetWithTags("a", 1, "t")
+	cache.Set("b", 2) // evicts a
+	cache.Set("c", 3) // evicts b
+
+	cache.Delete("b")
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected deleted b not to come back")
+	}
+	cache.InvalidateTag("t")
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected invalidated a not to come back")
+	}
+	if err := cache.checkInvariants(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
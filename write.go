@@ -0,0 +1,112 @@
+package lfu
+
+import "time"
+
+// writeThrough stores key in the backing store before it is cached,
+// retrying with exponential backoff. Runs without holding c.mu.
+func (c *LFUCache[K, V]) writeThrough(key K, value V) error {
+	err := c.retryWrite(func() error { return c.writer(key, value) })
+	if err != nil {
+		c.log("write failed", "key", key, "error", err)
+		if c.onWriteError != nil {
+			c.onWriteError(key, value, err)
+		}
+	}
+	return err
+}
+
+// retryWrite runs write up to writeRetries times, doubling the backoff
+// between attempts, and returns the last error.
+func (c *LFUCache[K, V]) retryWrite(write func() error) error {
+	backoff := c.writeBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = write(); err == nil || attempt >= c.writeRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// markDirty buffers key for the next write-behind flush. Later updates of
+// the same key replace earlier ones. Once Stop ended the flush loop, key
+// is flushed at once instead.
+func (c *LFUCache[K, V]) markDirty(key K, value V) {
+	c.dirtyMu.Lock()
+	if c.flushClosed {
+		c.dirtyMu.Unlock()
+		c.flushBatchBehind([]KeyValue[K, V]{{Key: key, Value: value}})
+		return
+	}
+	c.dirty[key] = value
+	full := len(c.dirty) >= c.flushBatch
+	c.dirtyMu.Unlock()
+	if full {
+		select {
+		case c.flushNow <- struct{}{}:
+		default: // a flush is already pending
+		}
+	}
+}
+
+func (c *LFUCache[K, V]) startWriteBehind() {
+	c.dirty = make(map[K]V)
+	c.flushNow = make(chan struct{}, 1)
+	c.flushDone = make(chan struct{})
+	go func() {
+		defer close(c.flushDone)
+		var tick <-chan time.Time
+		if c.flushInterval > 0 {
+			ticker := time.NewTicker(c.flushInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-tick:
+			case <-c.flushNow:
+			case <-c.stop:
+				c.dirtyMu.Lock()
+				c.flushClosed = true
+				c.dirtyMu.Unlock()
+				c.flushDirty()
+				return
+			}
+			c.flushDirty()
+		}
+	}()
+}
+
+// flushDirty hands the buffered updates to the flush function in batches,
+// retrying failed batches and reporting those that still fail.
+func (c *LFUCache[K, V]) flushDirty() {
+	c.dirtyMu.Lock()
+	dirty := c.dirty
+	c.dirty = make(map[K]V)
+	c.dirtyMu.Unlock()
+
+	batch := make([]KeyValue[K, V], 0, min(len(dirty), c.flushBatch))
+	for key, value := range dirty {
+		batch = append(batch, KeyValue[K, V]{Key: key, Value: value})
+		if len(batch) == c.flushBatch {
+			c.flushBatchBehind(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		c.flushBatchBehind(batch)
+	}
+}
+
+func (c *LFUCache[K, V]) flushBatchBehind(batch []KeyValue[K, V]) {
+	err := c.retryWrite(func() error { return c.flushBehind(batch) })
+	if err != nil {
+		c.log("flush failed", "entries", len(batch), "error", err)
+		if c.onWriteError != nil {
+			for _, kv := range batch {
+				c.onWriteError(kv.Key, kv.Value, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package lfu
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("store unavailable")
+
+// flakyWriter fails the first failures calls and records successful writes.
+type flakyWriter struct {
+	failures int
+	calls    int
+	stored   map[string]int
+}
+
+func (w *flakyWriter) write(k string, v int) error {
+	w.calls++
+	if w.calls <= w.failures {
+		return errUnavailable
+	}
+	if w.stored == nil {
+		w.stored = make(map[string]int)
+	}
+	w.stored[k] = v
+	return nil
+}
+
+// Test a writer that fails twice then succeeds is retried with backoff
+func TestWriteRetry(t *testing.T) {
+	w := &flakyWriter{failures: 2}
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithWriter(w.write), WithWriteRetry[string, int](3, 10*time.Millisecond))
+
+	start := time.Now()
+	if err := cache.SetWithError("a", 1); err != nil {
+		t.Fatalf("Expected write to succeed after retries, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected exponential backoff of at least 30ms, took %v", elapsed)
+	}
+	if w.calls != 3 || w.stored["a"] != 1 {
+		t.Errorf("Expected 3 attempts and a stored, got %d attempts, %v", w.calls, w.stored)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1 to be cached, got %v", v)
+	}
+}
+
+// Test exhausted retries are reported and the value isn't cached
+func TestWriteRetryExhausted(t *testing.T) {
+	w := &flakyWriter{failures: 5}
+	var reported error
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithWriter(w.write),
+		WithWriteRetry[string, int](2, time.Millisecond),
+		WithWriteErrorHandler(func(k string, v int, err error) { reported = err }))
+
+	if err := cache.SetWithError("a", 1); !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected write error, got %v", err)
+	}
+	if w.calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", w.calls)
+	}
+	if !errors.Is(reported, errUnavailable) {
+		t.Errorf("Expected error handler to be called, got %v", reported)
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected failed write not to be cached")
+	}
+}
+
+// batchRecorder records write-behind flushes, signalling each on flushed
+// if it is set.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]KeyValue[string, int]
+	flushed chan struct{}
+}
+
+func (r *batchRecorder) flush(batch []KeyValue[string, int]) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]KeyValue[string, int](nil), batch...))
+	r.mu.Unlock()
+	if r.flushed != nil {
+		r.flushed <- struct{}{}
+	}
+	return nil
+}
+
+// wait blocks until the next flush.
+func (r *batchRecorder) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.flushed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a flush")
+	}
+}
+
+func (r *batchRecorder) written() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]int)
+	for _, b := range r.batches {
+		for _, kv := range b {
+			out[kv.Key] = kv.Value
+		}
+	}
+	return out
+}
+
+// Test write-behind coalesces updates and flushes on batch size and interval
+func TestWriteBehind(t *testing.T) {
+	r := &batchRecorder{flushed: make(chan struct{}, 1)}
+	cache := newTestCache[string, int](10, time.Minute, nil,
+		WithWriteBehind(time.Hour, 2, r.flush))
+	defer cache.Stop()
+
+	cache.Set("a", 1)
+	cache.Set("a", 2) // coalesced with the previous update
+	if n := len(r.written()); n != 0 {
+		t.Errorf("Expected nothing flushed yet, got %d keys", n)
+	}
+
+	cache.Set("b", 3) // reaches the batch size
+	r.wait(t)
+	if got := r.written(); len(got) != 2 || got["a"] != 2 || got["b"] != 3 {
+		t.Errorf("Expected a=2 and b=3 flushed, got %v", got)
+	}
+
+	ticked := &batchRecorder{flushed: make(chan struct{}, 1)}
+	cache = newTestCache[string, int](10, time.Minute, nil,
+		WithWriteBehind(time.Millisecond, 100, ticked.flush))
+	defer cache.Stop()
+	cache.Set("c", 4)
+	ticked.wait(t) // flushed by the interval
+	if got := ticked.written(); got["c"] != 4 {
+		t.Errorf("Expected c flushed on the interval, got %v", got)
+	}
+}
+
+// Test Stop flushes the remaining buffer before returning
+func TestWriteBehindFlushOnStop(t *testing.T) {
+	r := &batchRecorder{}
+	cache := newTestCache[string, int](10, time.Minute, nil,
+		WithWriteBehind(time.Hour, 100, r.flush))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Stop()
+
+	if got := r.written(); len(got) != 2 {
+		t.Errorf("Expected 2 keys flushed on Stop, got %v", got)
+	}
+
+	if err := cache.SetWithError("c", 3); err != nil {
+		t.Fatalf("Expected no error after Stop, got %v", err)
+	}
+	if got := r.written(); got["c"] != 3 {
+		t.Errorf("Expected a Set after Stop to be flushed at once, got %v", got)
+	}
+}
+
+// Test NewWithError rejects nil options and write options that would drop data
+func TestNewWithErrorInvalidConfig(t *testing.T) {
+	invalid := map[string][]Option[string, int]{
+		"nil writer":         {WithWriter[string, int](nil)},
+		"nil flush":          {WithWriteBehind[string, int](time.Second, 10, nil)},
+		"retry only":         {WithWriteRetry[string, int](3, time.Millisecond)},
+		"error handler only": {WithWriteErrorHandler(func(string, int, error) {})},
+		"nil clock":          {WithClock[string, int](nil)},
+		"nil codec":          {WithCodec[string, int](nil)},
+	}
+	for name, opts := range invalid {
+		c, err := NewWithError(2, time.Minute, 0, nil, opts...)
+		if !errors.Is(err, ErrInvalidConfig) || c != nil {
+			t.Errorf("Expected ErrInvalidConfig for %s, got %v", name, err)
+		}
+	}
+
+	w := &flakyWriter{}
+	c, err := NewWithError(2, time.Minute, 0, nil,
+		WithWriter(w.write), WithWriteRetry[string, int](3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected a valid configuration, got %v", err)
+	}
+	defer c.Stop()
+	c.Set("a", 1)
+	if w.stored["a"] != 1 {
+		t.Errorf("Expected the write to go through")
+	}
+}
+
+// Test TrySet writes through and honors WithDeleteOnZero like Set
+func TestTrySetWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](2, time.Minute, nil, WithWriter(w.write),
+		WithDeleteOnZero[string, int](func(v int) bool { return v == 0 }))
+
+	if !cache.TrySet("a", 1, time.Second) || w.stored["a"] != 1 {
+		t.Errorf("Expected TrySet to write a through, got %v", w.stored)
+	}
+	cache.TrySet("a", 0, time.Second)
+	if cache.Contains("a") || w.calls != 1 {
+		t.Errorf("Expected a zero TrySet to delete a without writing, got %d writes", w.calls)
+	}
+}
+
+// Test SetReport writes through and honors WithDeleteOnZero like Set
+func TestSetReportWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](1, time.Minute, nil, WithWriter(w.write),
+		WithDeleteOnZero[string, int](func(v int) bool { return v == 0 }))
+
+	cache.SetReport("a", 1)
+	if evicted, k := cache.SetReport("b", 2); !evicted || k != "a" {
+		t.Errorf("Expected a to be evicted, got %v %q", evicted, k)
+	}
+	if w.stored["a"] != 1 || w.stored["b"] != 2 {
+		t.Errorf("Expected SetReport to write through, got %v", w.stored)
+	}
+	cache.SetReport("b", 0)
+	if cache.Contains("b") || w.calls != 2 {
+		t.Errorf("Expected a zero SetReport to delete b without writing, got %d writes", w.calls)
+	}
+}
+
+// Test SetWithDependencies writes through like Set
+func TestSetWithDependenciesWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](2, time.Minute, nil, WithWriter(w.write))
+	if err := cache.SetWithDependencies("b", 2, "a"); err != nil || w.stored["b"] != 2 {
+		t.Errorf("Expected b to be written through, got %v, %v", err, w.stored)
+	}
+	if err := cache.SetWithDependencies("a", 1, "b"); !errors.Is(err, ErrDependencyCycle) || w.calls != 1 {
+		t.Errorf("Expected a cycle to be refused before writing, got %v after %d writes", err, w.calls)
+	}
+}
+
+// Test SetWithTags writes through and is rejected after a strict Stop
+func TestSetWithTagsWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](2, time.Minute, nil,
+		WithWriter(w.write), WithStrictStop[string, int]())
+	cache.SetWithTags("a", 1, "t")
+	if w.stored["a"] != 1 {
+		t.Errorf("Expected SetWithTags to write a through, got %v", w.stored)
+	}
+	cache.Stop()
+	cache.SetWithTags("b", 2, "t")
+	if w.calls != 1 || cache.Contains("b") {
+		t.Errorf("Expected SetWithTags to be rejected after Stop, got %d writes", w.calls)
+	}
+}
+
+// Test SetWithDeadline writes through unless the deadline has passed
+func TestSetWithDeadlineWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](2, time.Minute, nil, WithWriter(w.write))
+	cache.SetWithDeadline("a", 1, time.Now().Add(time.Minute))
+	if w.stored["a"] != 1 {
+		t.Errorf("Expected SetWithDeadline to write a through, got %v", w.stored)
+	}
+	cache.SetWithDeadline("a", 2, time.Now().Add(-time.Minute))
+	if cache.Contains("a") || w.calls != 1 {
+		t.Errorf("Expected a past deadline to delete a without writing, got %d writes", w.calls)
+	}
+}
+
+// Test SetWithDecayWeight writes through and honors WithDeleteOnZero
+func TestSetWithDecayWeightWriter(t *testing.T) {
+	w := &flakyWriter{}
+	cache := newTestCache[string, int](2, time.Minute, nil, WithWriter(w.write),
+		WithDeleteOnZero[string, int](func(v int) bool { return v == 0 }))
+	cache.SetWithDecayWeight("a", 1, 0.5)
+	if w.stored["a"] != 1 {
+		t.Errorf("Expected SetWithDecayWeight to write a through, got %v", w.stored)
+	}
+	cache.SetWithDecayWeight("a", 0, 0.5)
+	if cache.Contains("a") || w.calls != 1 {
+		t.Errorf("Expected a zero value to delete a without writing, got %d writes", w.calls)
+	}
+}